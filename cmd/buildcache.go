@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MihaiZegheru/cfr/internal"
+)
+
+// buildCacheVersion is mixed into every key; bump it when the key layout changes.
+const buildCacheVersion = "cfr-build-1"
+
+// buildCacheEntries is how many binaries the cache keeps; the least recently used go first.
+const buildCacheEntries = 64
+
+// forceRebuild is set by --rebuild to ignore cached binaries.
+var forceRebuild bool
+
+// localInclude matches `#include "file"`, which is looked up next to the including file.
+var localInclude = regexp.MustCompile(`(?m)^\s*#\s*include\s*"([^"]+)"`)
+
+var (
+	compilerIDsMu sync.Mutex
+	compilerIDs   = map[string]string{}
+)
+
+// compilerID identifies a compiler by its resolved path and version output, "" if it cannot be
+// run. Results are remembered for the life of the process.
+func compilerID(compiler string) string {
+	compilerIDsMu.Lock()
+	defer compilerIDsMu.Unlock()
+	if id, ok := compilerIDs[compiler]; ok {
+		return id
+	}
+	id := ""
+	if path, err := exec.LookPath(compiler); err == nil {
+		arg := "--version"
+		if strings.TrimSuffix(filepath.Base(path), ".exe") == "go" {
+			arg = "version"
+		}
+		if out, err := runAndCapture(path, arg); err == nil {
+			id = path + "\n" + out
+		}
+	}
+	compilerIDs[compiler] = id
+	return id
+}
+
+// buildCacheKey hashes everything that decides what a compilation produces: the compiler and its
+// version, the flags and environment, the source and, for C-like languages, the local headers it
+// includes. It returns "" when the build cannot be cached.
+func buildCacheKey(l internal.Language, compiler string, args, env []string, sourceFile, binPath string, includeDirs []string) string {
+	if internal.BuildCacheDir() == "" {
+		return ""
+	}
+	id := compilerID(compiler)
+	if id == "" {
+		return ""
+	}
+	h := sha256.New()
+	write := func(parts ...string) {
+		for _, p := range parts {
+			io.WriteString(h, p)
+			h.Write([]byte{0})
+		}
+	}
+	write(buildCacheVersion, l.Name, id)
+	for _, a := range args {
+		// The paths differ between problems but do not change the binary.
+		switch a {
+		case sourceFile:
+			a = "<source>"
+		case binPath:
+			a = "<output>"
+		}
+		write(a)
+	}
+	write(env...)
+	files := []string{sourceFile}
+	if l.LocalIncludes {
+		files = append(files, localIncludes(sourceFile, includeDirs, map[string]bool{sourceFile: true})...)
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return ""
+		}
+		rel, _ := filepath.Rel(filepath.Dir(sourceFile), f)
+		write(filepath.ToSlash(rel), string(data))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func cachedBinaryPath(key string) string {
+	return filepath.Join(internal.BuildCacheDir(), key[:32]+".exe")
+}
+
+// restoreCachedBinary copies a cached binary to binPath and marks it as recently used.
+func restoreCachedBinary(key, binPath string) error {
+	src := cachedBinaryPath(key)
+	if err := copyExecutable(src, binPath); err != nil {
+		return err
+	}
+	now := time.Now()
+	os.Chtimes(src, now, now)
+	return nil
+}
+
+// storeCachedBinary adds a freshly compiled binary to the cache. Failures only cost a rebuild
+// later, so they are ignored.
+func storeCachedBinary(key, binPath string) {
+	if key == "" {
+		return
+	}
+	dir := internal.BuildCacheDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	tmp := cachedBinaryPath(key) + ".tmp"
+	if err := copyExecutable(binPath, tmp); err != nil {
+		os.Remove(tmp)
+		return
+	}
+	if err := os.Rename(tmp, cachedBinaryPath(key)); err != nil {
+		os.Remove(tmp)
+		return
+	}
+	pruneBuildCache(dir)
+}
+
+// pruneBuildCache removes the least recently used binaries beyond buildCacheEntries.
+func pruneBuildCache(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	type cached struct {
+		path string
+		used int64
+	}
+	var bins []cached
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || e.IsDir() {
+			continue
+		}
+		bins = append(bins, cached{filepath.Join(dir, e.Name()), info.ModTime().UnixNano()})
+	}
+	if len(bins) <= buildCacheEntries {
+		return
+	}
+	sort.Slice(bins, func(i, j int) bool { return bins[i].used > bins[j].used })
+	for _, b := range bins[buildCacheEntries:] {
+		os.Remove(b.path)
+	}
+}
+
+// copyExecutable copies src to dst with executable permissions, replacing dst.
+func copyExecutable(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	// Remove first: dst may still be mapped by a process from an earlier run.
+	os.Remove(dst)
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// localIncludes follows `#include "..."` directives from source, returning every existing file
+// reached that is not already in seen. Headers are looked up next to the including file, then in
+// includeDirs.
+func localIncludes(source string, includeDirs []string, seen map[string]bool) []string {
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for _, m := range localInclude.FindAllStringSubmatch(string(data), -1) {
+		for _, dir := range append([]string{filepath.Dir(source)}, includeDirs...) {
+			path := filepath.Join(dir, m[1])
+			if !fileExists(path) {
+				continue
+			}
+			if !seen[path] {
+				seen[path] = true
+				out = append(out, path)
+				out = append(out, localIncludes(path, includeDirs, seen)...)
+			}
+			break
+		}
+	}
+	return out
+}
+
+func fileExists(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && !fi.IsDir()
+}
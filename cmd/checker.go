@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/MihaiZegheru/cfr/internal"
+)
+
+// outputJudge decides the verdict for a run that exited normally, with a message for the report.
+type outputJudge func(input, expected, actual string) (verdict, string)
+
+// Exit codes of testlib checkers.
+const (
+	testlibOK   = 0
+	testlibWA   = 1
+	testlibPE   = 2
+	testlibFail = 3
+)
+
+// checkerTimeLimit bounds a single checker run; checkers are trusted but may still hang.
+const checkerTimeLimit = 10 * time.Second
+
+// comparatorJudge judges output with one of the built-in comparators.
+func comparatorJudge(compare internal.Comparator) outputJudge {
+	return func(input, expected, actual string) (verdict, string) {
+		if ok, msg := compare(expected, actual); !ok {
+			return verdictWA, msg
+		}
+		return verdictOK, ""
+	}
+}
+
+// findHelperSource returns the <name>.<ext> source file in probDir, such as checker.cpp or
+// interactor.py, or "" if there is none.
+func findHelperSource(cfg internal.Config, probDir, name string) string {
+	matches, _ := filepath.Glob(filepath.Join(probDir, name+".*"))
+	for _, m := range matches {
+		if cfg.LanguageForFile(m) != "" {
+			return m
+		}
+	}
+	return ""
+}
+
+// buildHelper compiles a checker or interactor source into <name>.exe next to it.
+func buildHelper(cfg internal.Config, source, name string) (program, error) {
+	l, ok := cfg.Language(cfg.LanguageForFile(source))
+	if !ok {
+		return program{}, fmt.Errorf("cannot tell the language of %s %s", name, source)
+	}
+	b, err := planBuild(cfg, l, source, filepath.Dir(source), name+".exe", releaseProfile)
+	if err != nil {
+		return program{}, err
+	}
+	if b.compiles() && !b.cached() {
+		fmt.Fprintf(statusOut, "Compiling %s %s...\n", name, source)
+	}
+	prog, err := b.build()
+	if ce, ok := err.(*compileError); ok {
+		return program{}, fmt.Errorf("%s compilation failed: %v\n%s", name, ce, ce.Output)
+	}
+	return prog, err
+}
+
+// resolveJudge turns a checker spec (from --checker or config.json) into a judge. The spec is
+// either a source file in the problem folder, compiled once here, or a built-in comparator name.
+// An empty spec picks up checker.<ext> from the problem folder, falling back to the default comparator.
+func resolveJudge(cfg internal.Config, spec, probDir string) (outputJudge, error) {
+	source := checkerSource(cfg, spec, probDir)
+	if source == "" {
+		compare, err := internal.LookupComparator(spec)
+		if err != nil {
+			return nil, fmt.Errorf("%v. Available checkers:\n  %s", err, strings.Join(internal.ComparatorHelp, "\n  "))
+		}
+		return comparatorJudge(compare), nil
+	}
+	prog, err := buildHelper(cfg, source, "checker")
+	if err != nil {
+		return nil, err
+	}
+	return checkerProgramJudge(prog), nil
+}
+
+// checkerSource returns the checker source file a checker spec refers to, or "" if the spec names
+// a built-in comparator.
+func checkerSource(cfg internal.Config, spec, probDir string) string {
+	if spec == "" {
+		return findHelperSource(cfg, probDir, "checker")
+	}
+	if _, err := os.Stat(filepath.Join(probDir, spec)); err == nil {
+		return filepath.Join(probDir, spec)
+	}
+	return ""
+}
+
+// checkerProgramJudge runs a testlib-compatible checker as `checker <input> <output> <answer>`
+// and maps its exit code to a verdict; the checker's message is what it wrote to stderr.
+func checkerProgramJudge(prog program) outputJudge {
+	return func(input, expected, actual string) (verdict, string) {
+		dir, err := os.MkdirTemp("", "cfr-check-")
+		if err != nil {
+			return verdictFail, err.Error()
+		}
+		defer os.RemoveAll(dir)
+		inPath := filepath.Join(dir, "input.txt")
+		outPath := filepath.Join(dir, "output.txt")
+		ansPath := filepath.Join(dir, "answer.txt")
+		for path, content := range map[string]string{inPath: input, outPath: actual, ansPath: expected} {
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				return verdictFail, err.Error()
+			}
+		}
+		args := append(append([]string{}, prog.Args...), inPath, outPath, ansPath)
+		res, err := runWithLimit(prog.Cmd, args, inPath, prog.Dir, runLimits{Time: checkerTimeLimit})
+		if err != nil {
+			return verdictFail, fmt.Sprintf("could not run checker: %v", err)
+		}
+		return testlibVerdict(res, "checker")
+	}
+}
+
+// testlibVerdict maps how a testlib checker or interactor exited to a verdict; its message is
+// what it wrote to stderr.
+func testlibVerdict(res runResult, name string) (verdict, string) {
+	msg := strings.TrimSpace(res.Stderr)
+	if msg == "" {
+		msg = strings.TrimSpace(res.Stdout)
+	}
+	switch {
+	case res.Verdict == verdictTLE:
+		return verdictFail, name + " timed out"
+	case res.Signal != "":
+		return verdictFail, name + " " + res.exitDescription()
+	}
+	switch res.ExitCode {
+	case testlibOK:
+		return verdictOK, msg
+	case testlibWA:
+		return verdictWA, msg
+	case testlibPE:
+		return verdictPE, msg
+	case testlibFail:
+		return verdictFail, msg
+	}
+	return verdictFail, fmt.Sprintf("%s exited with code %d: %s", name, res.ExitCode, msg)
+}
@@ -0,0 +1,283 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MihaiZegheru/cfr/internal"
+)
+
+// executableFor returns the compiler or interpreter for a language from the "executables" map
+// in config, under its name or an alias, or the language's default one.
+func executableFor(cfg internal.Config, l internal.Language) string {
+	for _, name := range append([]string{l.Name}, l.Aliases...) {
+		if exe, ok := cfg.Executables[name]; ok && exe != "" {
+			return exe
+		}
+	}
+	return l.Compiler
+}
+
+// program is a source file ready to run: either a compiled binary or a script plus its interpreter.
+type program struct {
+	Cmd  string
+	Args []string
+	// Dir is the working directory to run in, empty for the current one.
+	Dir string
+	// Env is added to the environment of every run, e.g. sanitizer options.
+	Env []string
+	// Startup is how long the runtime takes to start, left out of the measured time.
+	Startup time.Duration
+}
+
+// compileError is returned by buildPlan.build when the compiler rejects the source.
+type compileError struct {
+	err    error
+	Output string
+}
+
+func (e *compileError) Error() string {
+	return e.err.Error()
+}
+
+// buildPlan describes how a source file becomes a runnable program, worked out before anything
+// is compiled so callers can tell a cache hit from a real compilation.
+type buildPlan struct {
+	dir, binName string
+	// compiler and args are the compile command; compiler is empty for interpreted languages.
+	compiler string
+	args     []string
+	// sourceFile is the source as written; compileSource is the copy inside buildDir that is
+	// compiled instead, "" when the source is compiled in place.
+	sourceFile, compileSource string
+	// dropCopy removes compileSource again after compiling, for copies placed among the user's files.
+	dropCopy bool
+	// buildDir is created before compiling, "" when the language does not use {build}.
+	buildDir string
+	// artifact is where the compiler leaves the binary when that is not binName inside dir.
+	artifact string
+	// diagnostics, if set, tidies the compiler output shown on failure.
+	diagnostics func(string) string
+	// run is how the program is started once built; startup only starts its runtime.
+	run     program
+	startup []string
+	// key identifies the build in the build cache, "" when it cannot be cached.
+	key string
+	// env is set for the compiler and passed on to the program.
+	env []string
+}
+
+// debugBuild is set by --debug, a shorthand for --profile debug.
+var debugBuild bool
+
+// planBuild works out how to build sourceFile, written in l, into binName inside dir using the
+// named build profile ("" for release).
+func planBuild(cfg internal.Config, l internal.Language, sourceFile, dir, binName, profile string) (*buildPlan, error) {
+	if !l.Runnable() {
+		return nil, fmt.Errorf("language %q not supported for testing", l.Name)
+	}
+	lb, err := resolveBuild(cfg, profile, l)
+	if err != nil {
+		return nil, err
+	}
+	if usesCargo(l) {
+		return planCargoBuild(l, lb, sourceFile, dir, binName), nil
+	}
+	b := &buildPlan{dir: dir, binName: binName, env: buildEnv(lb), sourceFile: sourceFile}
+	if l.Name == "rust" {
+		b.diagnostics = tidyRustDiagnostics
+	}
+	binPath := filepath.Join(dir, binName)
+	// Each program gets its own build directory, so a checker's classes never mix with the solution's.
+	buildRel := filepath.Join("build", strings.TrimSuffix(binName, ".exe"))
+	if l.CompileAs != "" || usesPlaceholder(l, "{build}") {
+		b.buildDir = filepath.Join(dir, buildRel)
+	}
+	// The program runs inside dir, so its paths are relative to it.
+	source, err := filepath.Rel(dir, sourceFile)
+	if err != nil {
+		source = sourceFile
+	}
+	runVars := map[string]string{
+		"{compiler}": lb.Compiler,
+		"{runtime}":  lb.Runtime,
+		"{source}":   source,
+		"{binary}":   "." + string(os.PathSeparator) + binName,
+		"{build}":    buildRel,
+		"{dir}":      ".",
+	}
+	run := expandCommand(l.Run, lb, runVars)
+	b.run = program{Cmd: run[0], Args: run[1:], Dir: dir, Env: b.env}
+	if len(l.Startup) > 0 {
+		b.startup = expandCommand(l.Startup, lb, runVars)
+	}
+	if !l.Compiled() {
+		return b, nil
+	}
+	compileSource := sourceFile
+	if l.CompileAs != "" {
+		b.compileSource = filepath.Join(b.buildDir, l.CompileAs)
+		compileSource = b.compileSource
+	}
+	compile := expandCommand(l.Compile, lb, map[string]string{
+		"{compiler}": lb.Compiler,
+		"{runtime}":  lb.Runtime,
+		"{source}":   compileSource,
+		"{binary}":   binPath,
+		"{build}":    b.buildDir,
+		"{dir}":      dir,
+	})
+	b.compiler, b.args = compile[0], compile[1:]
+	// Anything but a single binary, such as a directory of classes, is rebuilt every time.
+	if usesPlaceholder(l, "{binary}") && b.compileSource == "" {
+		b.key = buildCacheKey(l, b.compiler, b.args, b.env, sourceFile, binPath, lb.IncludePaths)
+	}
+	return b, nil
+}
+
+// expandCommand fills in the placeholders of a language's command template, see internal.Language.
+func expandCommand(tmpl []string, lb internal.LanguageBuild, vars map[string]string) []string {
+	var pairs []string
+	for k, v := range vars {
+		pairs = append(pairs, k, v)
+	}
+	r := strings.NewReplacer(pairs...)
+	var out []string
+	for _, arg := range tmpl {
+		switch arg {
+		case "{flags}":
+			out = append(out, lb.Flags...)
+		case "{run_flags}":
+			out = append(out, lb.RunFlags...)
+		case "{defines}":
+			for _, d := range lb.Defines {
+				out = append(out, "-D"+d)
+			}
+		case "{includes}":
+			for _, p := range lb.IncludePaths {
+				out = append(out, "-I"+p)
+			}
+		default:
+			out = append(out, r.Replace(arg))
+		}
+	}
+	return out
+}
+
+// usesPlaceholder reports whether the compile command of l refers to placeholder.
+func usesPlaceholder(l internal.Language, placeholder string) bool {
+	for _, arg := range l.Compile {
+		if strings.Contains(arg, placeholder) {
+			return true
+		}
+	}
+	return false
+}
+
+// compiles reports whether the plan invokes a compiler.
+func (b *buildPlan) compiles() bool {
+	return b.compiler != ""
+}
+
+// cached reports whether the build cache already holds the binary, unless --rebuild was given.
+func (b *buildPlan) cached() bool {
+	return !forceRebuild && b.key != "" && fileExists(cachedBinaryPath(b.key))
+}
+
+// build compiles the program, or copies it out of the build cache, and returns how to run it.
+func (b *buildPlan) build() (program, error) {
+	if !b.compiles() {
+		return b.run, nil
+	}
+	binPath := filepath.Join(b.dir, b.binName)
+	if b.cached() && restoreCachedBinary(b.key, binPath) == nil {
+		return b.run, nil
+	}
+	if b.buildDir != "" {
+		// Start clean so classes of an earlier version cannot linger.
+		os.RemoveAll(b.buildDir)
+		if err := os.MkdirAll(b.buildDir, 0755); err != nil {
+			return program{}, err
+		}
+	}
+	if b.compileSource != "" {
+		data, err := os.ReadFile(b.sourceFile)
+		if err != nil {
+			return program{}, err
+		}
+		if b.dropCopy {
+			defer b.removeCopy()
+		}
+		if err := os.MkdirAll(filepath.Dir(b.compileSource), 0755); err != nil {
+			return program{}, err
+		}
+		if err := os.WriteFile(b.compileSource, data, 0644); err != nil {
+			return program{}, err
+		}
+	}
+	out, err := runAndCaptureEnv(b.env, b.compiler, b.args...)
+	if err != nil {
+		if b.compileSource != "" {
+			// Point diagnostics at the file the user edits rather than the copy.
+			out = strings.ReplaceAll(out, b.compileSource, b.sourceFile)
+		}
+		if b.diagnostics != nil {
+			out = b.diagnostics(out)
+		}
+		return program{}, &compileError{err: err, Output: out}
+	}
+	if b.artifact != "" {
+		if err := copyExecutable(b.artifact, binPath); err != nil {
+			return program{}, err
+		}
+	}
+	storeCachedBinary(b.key, binPath)
+	return b.run, nil
+}
+
+// removeCopy deletes the copy of the source that was compiled, and the folder it was put in if
+// that is now empty.
+func (b *buildPlan) removeCopy() {
+	os.Remove(b.compileSource)
+	os.Remove(filepath.Dir(b.compileSource))
+}
+
+var (
+	startupTimesMu sync.Mutex
+	startupTimes   = map[string]time.Duration{}
+)
+
+// startupTime measures how long the program's runtime takes to start, 0 if the language has no
+// startup command. The fastest of a few runs is taken, and remembered for the life of the process.
+func (b *buildPlan) startupTime() time.Duration {
+	if len(b.startup) == 0 {
+		return 0
+	}
+	key := strings.Join(append([]string{b.dir}, b.startup...), "\x00")
+	startupTimesMu.Lock()
+	defer startupTimesMu.Unlock()
+	if d, ok := startupTimes[key]; ok {
+		return d
+	}
+	var best time.Duration
+	for i := 0; i < 3; i++ {
+		c := exec.Command(b.startup[0], b.startup[1:]...)
+		c.Dir = b.dir
+		c.Env = append(os.Environ(), b.env...)
+		start := time.Now()
+		if err := c.Run(); err != nil {
+			best = 0
+			break
+		}
+		if d := time.Since(start); best == 0 || d < best {
+			best = d
+		}
+	}
+	startupTimes[key] = best
+	return best
+}
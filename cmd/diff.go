@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/MihaiZegheru/cfr/internal"
+)
+
+// ANSI escapes used by the diff view.
+const (
+	ansiReset = "\033[0m"
+	ansiRed   = "\033[31m"
+	ansiGreen = "\033[32m"
+	ansiDim   = "\033[2m"
+	ansiBold  = "\033[1;4m"
+)
+
+const (
+	// diffContextBefore and diffContextAfter are how many lines are shown around the first mismatch.
+	diffContextBefore = 3
+	diffContextAfter  = 8
+	// diffMaxWidth is the longest line shown in full; longer ones are cropped around the mismatch.
+	diffMaxWidth = 100
+)
+
+// useColor reports whether stdout is a terminal that should get colored output. NO_COLOR
+// (https://no-color.org) and TERM=dumb turn colors off.
+func useColor() bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return isTerminal(os.Stdout)
+}
+
+// printOutputDiff prints a unified, line-numbered diff of the expected and actual output around
+// the first differing line, with the first differing token highlighted (or marked with a caret
+// when colors are off). Lines are compared after trimming trailing whitespace.
+func printOutputDiff(expected, actual string, indent string) {
+	exp := splitOutputLines(expected)
+	act := splitOutputLines(actual)
+	color := useColor()
+	paint := func(code, s string) string {
+		if !color {
+			return s
+		}
+		return code + s + ansiReset
+	}
+
+	first := 0
+	for first < len(exp) && first < len(act) && exp[first] == act[first] {
+		first++
+	}
+	total := max(len(exp), len(act))
+	from := max(0, first-diffContextBefore)
+	to := min(total, first+diffContextAfter+1)
+	width := len(fmt.Sprint(to))
+
+	fmt.Println(indent + "Diff (" + paint(ansiGreen, "- expected") + ", " + paint(ansiRed, "+ yours") + "):")
+	if from > 0 {
+		fmt.Println(indent + paint(ansiDim, fmt.Sprintf("  ... %d matching line(s)", from)))
+	}
+	for i := from; i < to; i++ {
+		num := fmt.Sprintf("%*d", width, i+1)
+		if i < len(exp) && i < len(act) && exp[i] == act[i] {
+			fmt.Println(indent + paint(ansiDim, "  "+num+" | ") + crop(exp[i], 0))
+			continue
+		}
+		e, a := "", ""
+		if i < len(exp) {
+			e = exp[i]
+		}
+		if i < len(act) {
+			a = act[i]
+		}
+		tok := -1
+		if i == first {
+			tok = firstDifferentToken(e, a)
+		}
+		if i < len(exp) {
+			fmt.Println(indent + paint(ansiGreen, "- "+num+" | ") + highlight(e, tok, color, ansiGreen))
+		} else {
+			fmt.Println(indent + paint(ansiGreen, "- "+num+" | ") + paint(ansiDim, "(no line)"))
+		}
+		if i < len(act) {
+			fmt.Println(indent + paint(ansiRed, "+ "+num+" | ") + highlight(a, tok, color, ansiRed))
+		} else {
+			fmt.Println(indent + paint(ansiRed, "+ "+num+" | ") + paint(ansiDim, "(no line)"))
+		}
+		if tok >= 0 && !color && i < len(act) {
+			col, _ := tokenSpan(a, tok)
+			fmt.Println(indent + strings.Repeat(" ", len("+ "+num+" | ")+shownOffset(a, col)) + "^")
+		}
+	}
+	if to < total {
+		fmt.Println(indent + paint(ansiDim, fmt.Sprintf("  ... %d more line(s)", total-to)))
+	}
+}
+
+// splitOutputLines splits output into lines with trailing whitespace and blank lines removed.
+func splitOutputLines(s string) []string {
+	s = internal.NormalizeLines(s)
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// firstDifferentToken returns the index of the first whitespace-separated token that differs
+// between two lines.
+func firstDifferentToken(expected, actual string) int {
+	e, a := strings.Fields(expected), strings.Fields(actual)
+	k := 0
+	for k < len(e) && k < len(a) && e[k] == a[k] {
+		k++
+	}
+	return k
+}
+
+// tokenSpan returns the byte offset and length of the k-th token of line, or the end of the
+// line if it has fewer tokens.
+func tokenSpan(line string, k int) (int, int) {
+	i := 0
+	for {
+		for i < len(line) && unicode.IsSpace(rune(line[i])) {
+			i++
+		}
+		start := i
+		for i < len(line) && !unicode.IsSpace(rune(line[i])) {
+			i++
+		}
+		if start == len(line) || k == 0 {
+			return start, i - start
+		}
+		k--
+	}
+}
+
+// highlight crops a line around its tok-th token and, if colors are on, emphasizes that token.
+// A negative tok leaves the line as it is apart from cropping.
+func highlight(line string, tok int, color bool, code string) string {
+	if tok < 0 {
+		return crop(line, 0)
+	}
+	col, n := tokenSpan(line, tok)
+	shown := crop(line, col)
+	if !color || n == 0 {
+		return shown
+	}
+	from := shownOffset(line, col)
+	to := min(len(shown), from+n)
+	return shown[:from] + ansiBold + code + shown[from:to] + ansiReset + shown[to:]
+}
+
+// shownOffset maps a byte offset in line to the same position in crop(line, col).
+func shownOffset(line string, col int) int {
+	if len(line) <= diffMaxWidth {
+		return col
+	}
+	start := cropStart(line, col)
+	if start == 0 {
+		return col
+	}
+	return col - start + len("...")
+}
+
+// cropStart is where a long line is cut so that col stays visible.
+func cropStart(line string, col int) int {
+	start := max(0, col-diffMaxWidth/2)
+	return min(start, max(0, len(line)-diffMaxWidth))
+}
+
+// crop shortens lines longer than diffMaxWidth to a window around col.
+func crop(line string, col int) string {
+	if len(line) <= diffMaxWidth {
+		return line
+	}
+	start := cropStart(line, col)
+	end := start + diffMaxWidth
+	out := line[start:end]
+	if start > 0 {
+		out = "..." + out
+	}
+	if end < len(line) {
+		out += "..."
+	}
+	return out
+}
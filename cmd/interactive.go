@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/MihaiZegheru/cfr/internal"
+)
+
+// interactorTimeSlack is how much longer than the solution the interactor may run.
+const interactorTimeSlack = checkerTimeLimit
+
+// transcriptKeepBytes is roughly how much of the end of a transcript is kept. Interactions that
+// go wrong usually do so at the end, and a runaway one must not fill cfr's memory.
+const transcriptKeepBytes = 1 << 20
+
+// resolveInteractor finds the interactor for a problem: the file named by "interactor" in
+// config.json, or interactor.<ext> in the problem folder. It returns nil if the problem is not
+// interactive, otherwise the compiled interactor.
+func resolveInteractor(cfg internal.Config, problemID, probDir string) (*program, error) {
+	source := findHelperSource(cfg, probDir, "interactor")
+	if name := cfg.Problem(problemID).Interactor; name != "" {
+		source = filepath.Join(probDir, name)
+		if _, err := os.Stat(source); err != nil {
+			return nil, err
+		}
+	}
+	if source == "" {
+		return nil, nil
+	}
+	prog, err := buildHelper(cfg, source, "interactor")
+	if err != nil {
+		return nil, err
+	}
+	return &prog, nil
+}
+
+// interaction is the outcome of running a solution against an interactor on one test.
+type interaction struct {
+	Solution   runResult
+	Interactor runResult
+	// Transcript has one line per message: "> " for solution output, "< " for interactor output.
+	// Only the end of a long transcript is kept.
+	Transcript string
+	Verdict    verdict
+	Message    string
+}
+
+// runInteraction connects the solution's stdin/stdout to the interactor's stdout/stdin and runs
+// both under limits. The interactor is started testlib-style as `interactor <input> <output>`,
+// where input holds the test and output is a scratch file it may write its own log to.
+func runInteraction(sol program, lim runLimits, inter program, input string) (interaction, error) {
+	var it interaction
+	dir, err := os.MkdirTemp("", "cfr-interact-")
+	if err != nil {
+		return it, err
+	}
+	defer os.RemoveAll(dir)
+	inPath := filepath.Join(dir, "input.txt")
+	outPath := filepath.Join(dir, "output.txt")
+	if err := os.WriteFile(inPath, []byte(input), 0644); err != nil {
+		return it, err
+	}
+
+	// Both directions go through relays owned by cfr so every message can be recorded, and so the
+	// solution's output counts against its output limit.
+	solIn, toSol, err := os.Pipe()
+	if err != nil {
+		return it, err
+	}
+	fromSol, solOut, err := os.Pipe()
+	if err != nil {
+		return it, err
+	}
+	interIn, toInter, err := os.Pipe()
+	if err != nil {
+		return it, err
+	}
+	fromInter, interOut, err := os.Pipe()
+	if err != nil {
+		return it, err
+	}
+	childEnds := []*os.File{solIn, solOut, interIn, interOut}
+	closeAll := func(files []*os.File) {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+	defer closeAll([]*os.File{toSol, fromSol, toInter, fromInter})
+
+	interArgs := append(append([]string{}, inter.Args...), inPath, outPath)
+	interLim := runLimits{Time: lim.Time + interactorTimeSlack}
+	ip, err := startProgram(runSpec{Cmd: inter.Cmd, Args: interArgs, Dir: inter.Dir, Limits: interLim, Stdin: interIn, Stdout: interOut})
+	if err != nil {
+		closeAll(childEnds)
+		return it, err
+	}
+	sp, err := startProgram(runSpec{Cmd: sol.Cmd, Args: sol.Args, Dir: sol.Dir, Env: sol.Env, Limits: lim, Startup: sol.Startup, Stdin: solIn, Stdout: solOut})
+	if err != nil {
+		closeAll(childEnds)
+		ip.kill()
+		ip.wait()
+		return it, err
+	}
+	closeAll(childEnds)
+
+	var t transcript
+	var relays sync.WaitGroup
+	relays.Add(2)
+	go func() { defer relays.Done(); t.relay(toInter, fromSol, "> ", sp.takeOutput) }()
+	go func() { defer relays.Done(); t.relay(toSol, fromInter, "< ", nil) }()
+
+	// An interactor that gives up decides the verdict even if the solution then hangs or crashes
+	// on the closed pipe, so the solution is stopped right away.
+	var done sync.WaitGroup
+	done.Add(2)
+	go func() {
+		defer done.Done()
+		it.Solution = sp.wait()
+	}()
+	go func() {
+		defer done.Done()
+		it.Interactor = ip.wait()
+		if v, _ := testlibVerdict(it.Interactor, "interactor"); v != verdictOK {
+			sp.kill()
+		}
+	}()
+	done.Wait()
+	relays.Wait()
+	it.Transcript = t.String()
+
+	// Which side finished first is judged by when each process was reaped, not by which wait
+	// returned first. A solution cfr killed for a limit keeps that verdict: the interactor only
+	// failed because its input ended.
+	interFirst := it.Interactor.Exited.Before(it.Solution.Exited)
+	killedForLimit := it.Solution.Verdict == verdictTLE || it.Solution.Verdict == verdictOLE
+	iv, imsg := testlibVerdict(it.Interactor, "interactor")
+	switch {
+	case iv != verdictOK && interFirst && !killedForLimit:
+		it.Verdict, it.Message = iv, imsg
+	case it.Solution.Verdict != "":
+		it.Verdict, it.Message = it.Solution.Verdict, ""
+	default:
+		it.Verdict, it.Message = iv, imsg
+	}
+	return it, nil
+}
+
+// transcript records the messages relayed between a solution and its interactor, keeping about
+// transcriptKeepBytes of the most recent ones.
+type transcript struct {
+	mu      sync.Mutex
+	b       []byte
+	dropped int
+	partial map[string]string
+}
+
+// relay copies src to dst, recording everything with prefix, and closes dst at EOF. If take is
+// set, it is told about every chunk and returns how much of it may pass; the rest is dropped. Once
+// dst stops accepting data, src is still drained so the writer never blocks on a full pipe.
+func (t *transcript) relay(dst io.WriteCloser, src io.Reader, prefix string, take func(int) int) {
+	buf := make([]byte, 32*1024)
+	broken := false
+	for {
+		n, err := src.Read(buf)
+		if take != nil && n > 0 {
+			n = take(n)
+		}
+		if n > 0 {
+			t.record(prefix, string(buf[:n]))
+			if !broken {
+				if _, werr := dst.Write(buf[:n]); werr != nil {
+					broken = true
+				}
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	dst.Close()
+	t.flush(prefix)
+}
+
+func (t *transcript) record(prefix, chunk string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.partial == nil {
+		t.partial = map[string]string{}
+	}
+	pending := t.partial[prefix] + chunk
+	for {
+		line, rest, ok := strings.Cut(pending, "\n")
+		if !ok {
+			break
+		}
+		t.add(prefix + strings.TrimRight(line, "\r") + "\n")
+		pending = rest
+	}
+	// A message without newlines is cut into lines so it cannot grow without bound either.
+	if len(pending) > transcriptKeepBytes {
+		t.add(prefix + pending + "\n")
+		pending = ""
+	}
+	t.partial[prefix] = pending
+}
+
+// add appends a line, dropping the oldest ones once well over transcriptKeepBytes are held.
+func (t *transcript) add(line string) {
+	t.b = append(t.b, line...)
+	if len(t.b) <= 2*transcriptKeepBytes {
+		return
+	}
+	cut := len(t.b) - transcriptKeepBytes
+	if i := bytes.IndexByte(t.b[cut:], '\n'); i >= 0 && cut+i+1 < len(t.b) {
+		cut += i + 1
+	}
+	t.dropped += bytes.Count(t.b[:cut], []byte("\n"))
+	t.b = append(t.b[:0], t.b[cut:]...)
+}
+
+func (t *transcript) flush(prefix string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p := t.partial[prefix]; p != "" {
+		t.add(prefix + p + "\n")
+		t.partial[prefix] = ""
+	}
+}
+
+func (t *transcript) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.dropped > 0 {
+		return fmt.Sprintf("... %d earlier line(s) dropped\n", t.dropped) + string(t.b)
+	}
+	return string(t.b)
+}
@@ -0,0 +1,32 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// wrapLimited rewrites a command so it runs under the given limits. A tiny sh script applies them
+// with ulimit and then execs the real program, so the limits (and the pid) carry over to it.
+// The memory limit caps the address space and, like on Codeforces, lets the stack grow up to it.
+func wrapLimited(cmd string, args []string, lim runLimits) (string, []string) {
+	var script []string
+	if lim.MemoryMB > 0 {
+		kb := lim.MemoryMB * 1024
+		script = append(script, fmt.Sprintf("ulimit -s %d 2>/dev/null", kb))
+		if !lim.NoAddressLimit {
+			script = append(script, fmt.Sprintf("ulimit -v %d 2>/dev/null", kb))
+		}
+	}
+	if lim.Time > 0 {
+		// Round up and leave a second of slack; the wall-clock timer is the primary deadline.
+		cpuSecs := int((lim.Time+999_999_999)/1_000_000_000) + 1
+		script = append(script, fmt.Sprintf("ulimit -t %d 2>/dev/null", cpuSecs))
+	}
+	if len(script) == 0 {
+		return cmd, args
+	}
+	script = append(script, `exec "$0" "$@"`)
+	return "/bin/sh", append([]string{"-c", strings.Join(script, "; "), cmd}, args...)
+}
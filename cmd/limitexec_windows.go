@@ -0,0 +1,9 @@
+//go:build windows
+
+package cmd
+
+// wrapLimited is a no-op on Windows: memory is not capped up front and an overrun is only
+// detected from the solution's own allocation failure messages.
+func wrapLimited(cmd string, args []string, lim runLimits) (string, []string) {
+	return cmd, args
+}
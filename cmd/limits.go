@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/MihaiZegheru/cfr/internal"
+)
+
+// defaultTimeLimit is used when neither the statement nor config.json gives a time limit.
+const defaultTimeLimit = 2 * time.Second
+
+// defaultMemoryLimitMB is used when neither the statement nor config.json gives a memory limit.
+const defaultMemoryLimitMB = 256
+
+// defaultOutputLimitMB is used when config.json sets no output limit.
+const defaultOutputLimitMB = 64
+
+// problemTimeLimit returns the per-test time limit: the config.json override if set,
+// otherwise the limit scraped from the statement, otherwise defaultTimeLimit.
+func problemTimeLimit(cfg internal.Config, problemID string, prob internal.ProblemEntry) time.Duration {
+	if ms := cfg.Problem(problemID).TimeLimitMs; ms > 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	if prob.TimeLimitMs > 0 {
+		return time.Duration(prob.TimeLimitMs) * time.Millisecond
+	}
+	return defaultTimeLimit
+}
+
+// problemMemoryLimitMB resolves the per-test memory limit the same way as problemTimeLimit.
+func problemMemoryLimitMB(cfg internal.Config, problemID string, prob internal.ProblemEntry) int {
+	if mb := cfg.Problem(problemID).MemoryLimitMB; mb > 0 {
+		return mb
+	}
+	if prob.MemoryLimitMB > 0 {
+		return prob.MemoryLimitMB
+	}
+	return defaultMemoryLimitMB
+}
+
+// problemOutputLimitMB returns the output limit: the problem's override, then the workspace-wide
+// one, then defaultOutputLimitMB. Statements never give one.
+func problemOutputLimitMB(cfg internal.Config, problemID string) int {
+	if mb := cfg.Problem(problemID).OutputLimitMB; mb > 0 {
+		return mb
+	}
+	if cfg.OutputLimitMB > 0 {
+		return cfg.OutputLimitMB
+	}
+	return defaultOutputLimitMB
+}
+
+// problemLimits bundles the resolved limits for running a problem's solution.
+func problemLimits(cfg internal.Config, problemID string, prob internal.ProblemEntry) runLimits {
+	return runLimits{
+		Time:     problemTimeLimit(cfg, problemID, prob),
+		MemoryMB: problemMemoryLimitMB(cfg, problemID, prob),
+		OutputMB: problemOutputLimitMB(cfg, problemID),
+	}
+}
+
+// describeMemoryExceeded explains a Memory Limit Exceeded result for the report.
+func describeMemoryExceeded(res runResult, limitMB int) string {
+	peakMB := float64(res.PeakMemoryKB) / 1024
+	if peakMB > float64(limitMB) {
+		return fmt.Sprintf("peak %.1f MB, limit %d MB", peakMB, limitMB)
+	}
+	return fmt.Sprintf("allocation failed, limit %d MB", limitMB)
+}
+
+// usageWarningRatio is the share of a limit above which a run that stayed within it is flagged.
+const usageWarningRatio = 0.8
+
+// describeUsage formats the resources a run used for its verdict line.
+func describeUsage(user, sys, wall time.Duration, peakKB int64) string {
+	return fmt.Sprintf("user %.2fs, sys %.2fs, wall %.2fs, %.1f MB", user.Seconds(), sys.Seconds(), wall.Seconds(), float64(peakKB)/1024)
+}
+
+// usageWarnings flags CPU time, wall time and peak memory above usageWarningRatio of the limits.
+func usageWarnings(user, sys, wall time.Duration, peakKB int64, lim runLimits) []string {
+	var warnings []string
+	if lim.Time > 0 {
+		for _, t := range []struct {
+			name string
+			d    time.Duration
+		}{{"CPU time", user + sys}, {"wall time", wall}} {
+			if ratio := t.d.Seconds() / lim.Time.Seconds(); ratio > usageWarningRatio {
+				warnings = append(warnings, fmt.Sprintf("%s %.2fs is %.0f%% of the %.2fs time limit", t.name, t.d.Seconds(), ratio*100, lim.Time.Seconds()))
+			}
+		}
+	}
+	if lim.MemoryMB > 0 {
+		peakMB := float64(peakKB) / 1024
+		if ratio := peakMB / float64(lim.MemoryMB); ratio > usageWarningRatio {
+			warnings = append(warnings, fmt.Sprintf("peak memory %.1f MB is %.0f%% of the %d MB memory limit", peakMB, ratio*100, lim.MemoryMB))
+		}
+	}
+	return warnings
+}
+
+// resultUsage is describeUsage and usageWarnings for a run; limits that were exceeded are
+// already in the verdict, so they get no warnings.
+func resultUsage(res runResult, lim runLimits) (string, []string) {
+	usage := describeUsage(res.UserTime, res.SysTime, res.Elapsed, res.PeakMemoryKB)
+	if res.Verdict == verdictTLE || res.Verdict == verdictMLE {
+		return usage, nil
+	}
+	return usage, usageWarnings(res.UserTime, res.SysTime, res.Elapsed, res.PeakMemoryKB, lim)
+}
@@ -1,10 +1,10 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"regexp"
 	"html"
@@ -175,6 +175,32 @@ $]+)\$([a-zA-Z0-9])`).ReplaceAllString(md, "$${1}$ $2")
 	md = regexp.MustCompile(`(^|[\s\(\[\{])\$([a-zA-Z0-9])`).ReplaceAllString(md, "$1$2")
 	return strings.TrimSpace(md) + "\n"
 }
+// parseTimeLimitMs extracts the limit from the statement's "time limit per test2 seconds" header, 0 if absent.
+func parseTimeLimitMs(text string) int {
+	m := regexp.MustCompile(`([\d.]+)\s*seconds?`).FindStringSubmatch(text)
+	if len(m) != 2 {
+		return 0
+	}
+	secs, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0
+	}
+	return int(secs * 1000)
+}
+
+// parseMemoryLimitMB extracts the limit from the statement's "memory limit per test256 megabytes" header, 0 if absent.
+func parseMemoryLimitMB(text string) int {
+	m := regexp.MustCompile(`(\d+)\s*megabytes?`).FindStringSubmatch(text)
+	if len(m) != 2 {
+		return 0
+	}
+	mb, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0
+	}
+	return mb
+}
+
 var loadCmd = &cobra.Command{
     Use:   "load <ID>",
     Short: "Load a problem by ID",
@@ -242,6 +268,8 @@ var loadCmd = &cobra.Command{
 					probURL := "https://codeforces.com" + href
 					tests := []internal.TestCase{}
 					var problemMarkdown string
+					timeLimitMs := 0
+					memoryLimitMB := 0
 					// Use the same client and headers as for the contest page
 					probReq, err := http.NewRequest("GET", probURL, nil)
 					if err == nil {
@@ -259,6 +287,8 @@ var loadCmd = &cobra.Command{
 				       if err == nil && statementHtml != "" {
 					       problemMarkdown = htmlToMarkdown(statementHtml)
 				       }
+								timeLimitMs = parseTimeLimitMs(doc2.Find("div.problem-statement div.time-limit").Text())
+								memoryLimitMB = parseMemoryLimitMB(doc2.Find("div.problem-statement div.memory-limit").Text())
 								// ...existing code for sample test extraction...
 								var inputs, outputs []string
 								doc2.Find("div.sample-test div.input pre").Each(func(i int, s *goquery.Selection) {
@@ -303,18 +333,20 @@ var loadCmd = &cobra.Command{
 									outputs = append(outputs, strings.TrimSpace(htmlStr))
 								})
 								for i := 0; i < len(inputs) && i < len(outputs); i++ {
-									tests = append(tests, internal.TestCase{Input: inputs[i], Output: outputs[i]})
+									tests = append(tests, internal.TestCase{Input: inputs[i], Output: outputs[i], Kind: internal.TestSample})
 								}
 							}
 						}
 					}
-					problems[probID] = internal.ProblemEntry{URL: probURL, Name: probName, Tests: tests}
+					problems[probID] = internal.ProblemEntry{URL: probURL, Name: probName, Tests: tests, TimeLimitMs: timeLimitMs, MemoryLimitMB: memoryLimitMB}
 					// Store markdown for writing after directory creation
 					if probName != "" && problemMarkdown != "" {
 						problems[probID] = internal.ProblemEntry{
 							URL: probURL,
 							Name: probName,
 							Tests: tests,
+							TimeLimitMs: timeLimitMs,
+							MemoryLimitMB: memoryLimitMB,
 							// Add a new field if needed for markdown, or handle after folder creation
 						}
 						// We'll write the markdown after all folders are created below
@@ -328,6 +360,17 @@ var loadCmd = &cobra.Command{
 			fmt.Println("No problems found for this contest.")
 			return
 		}
+		// Replace the sample test files but keep the tests added with 'cfr tests add' across reloads
+		for pid, prob := range problems {
+			prev := prevState.Problems[pid]
+			for _, t := range prev.Tests {
+				if t.TestKind() == internal.TestSample {
+					internal.RemoveTest(internal.ProblemDir(pid, prev), t.Name)
+				}
+			}
+			prob.Tests = append(prob.Tests, prev.CustomTests()...)
+			problems[pid] = prob
+		}
 		// Save unified state
 		state := internal.ProblemsState{
 			ContestID: id,
@@ -342,32 +385,7 @@ var loadCmd = &cobra.Command{
 
 		// Only create files/folders if this is the first load (not a reload)
 		if prevState.ContestID == "" {
-			   configPath := ".cfr/config.json"
-			   lang := "cpp"
-			   ext := ".cpp"
-			   if f, err := os.Open(configPath); err == nil {
-				   defer f.Close()
-				   var cfg struct {
-					   DefaultLanguage string `json:"default_language"`
-				   }
-				   dec := json.NewDecoder(f)
-				   if err := dec.Decode(&cfg); err == nil {
-					   if cfg.DefaultLanguage != "" {
-						   lang = strings.ToLower(cfg.DefaultLanguage)
-					   }
-				   }
-			   }
-			   extMap := map[string]string{
-				   "c": ".c",
-				   "cpp": ".cpp",
-				   "c++": ".cpp",
-				   "python": ".py",
-				   "py": ".py",
-				   "go": ".go",
-			   }
-			   if e, ok := extMap[lang]; ok {
-				   ext = e
-			   }
+			   cfg, _ := internal.LoadConfig()
 			   // Write markdowns after all problems are processed
 			   for pid, prob := range problems {
 				   dirName := fmt.Sprintf("%s. %s", pid, prob.Name)
@@ -376,7 +394,11 @@ var loadCmd = &cobra.Command{
 				   outPath := dirName + string(os.PathSeparator) + "out.txt"
 				   os.WriteFile(inPath, []byte{}, 0644)
 				   os.WriteFile(outPath, []byte{}, 0644)
-				   srcPath := dirName + string(os.PathSeparator) + "main" + ext
+				   source := "main.cpp"
+				   if l, ok := cfg.Language(cfg.LanguageFor(pid)); ok {
+					   source = l.SourceFileName()
+				   }
+				   srcPath := dirName + string(os.PathSeparator) + source
 				   if _, err := os.Stat(srcPath); os.IsNotExist(err) {
 					   f, err := os.Create(srcPath)
 					   if err == nil {
@@ -393,7 +415,7 @@ var loadCmd = &cobra.Command{
 			   }
 			   fmt.Printf("Created source and IO files for problems.\n")
 		} else {
-			fmt.Println("Only sample tests were updated. No files or folders were changed.")
+			fmt.Println("Only sample tests were updated; custom tests were kept. No other files or folders were changed.")
 		}
 		fmt.Println("Done.")
 	},
@@ -0,0 +1,136 @@
+//go:build linux
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// A running program's memory is looked at every memoryPollMin at first, then with pauses that
+// double up to memoryPollMax, starting over whenever it execs. The pauses are slept in a system
+// call, since the runtime's timers are too coarse for a program that is done in a millisecond.
+const (
+	memoryPollMin = 50 * time.Microsecond
+	memoryPollMax = 5 * time.Millisecond
+)
+
+// rssSlackKB covers the kernel's per-CPU batching of RSS counters, which makes them approximate.
+const rssSlackKB = 1024
+
+// memoryWatch follows a program's peak resident memory (VmHWM) through /proc while it runs.
+// ru_maxrss alone is not enough: a process started by cfr keeps cfr's own high-water mark across
+// exec, so anything smaller than cfr would be reported at cfr's size.
+type memoryWatch struct {
+	// status stays bound to the process, so a pid reused after it is reaped is never read.
+	status *os.File
+	// inheritedKB bounds what ru_maxrss carries over from cfr, the sh wrapper and the sandbox
+	// helper; peakKB is the program's own high-water mark once it runs.
+	inheritedKB, peakKB int64
+	stop, done          chan struct{}
+}
+
+// watchMemory starts following the memory of the process pid, or returns nil if /proc is not
+// available.
+func watchMemory(pid int) *memoryWatch {
+	f, err := os.Open("/proc/" + strconv.Itoa(pid) + "/status")
+	if err != nil {
+		return nil
+	}
+	w := &memoryWatch{status: f, stop: make(chan struct{}), done: make(chan struct{})}
+	if self, err := os.ReadFile("/proc/self/status"); err == nil {
+		_, w.inheritedKB = parseProcStatus(self)
+	}
+	go w.poll()
+	return w
+}
+
+func (w *memoryWatch) poll() {
+	defer close(w.done)
+	wrappers := map[string]bool{"sh": true}
+	if self, err := os.Executable(); err == nil {
+		wrappers[commName(filepath.Base(self))] = true
+	}
+	buf := make([]byte, 4096)
+	last := ""
+	pause := memoryPollMin
+	for {
+		n, err := w.status.ReadAt(buf, 0)
+		if n == 0 && err != nil {
+			// The process is gone.
+			return
+		}
+		name, hwm := parseProcStatus(buf[:n])
+		if name != last {
+			last, pause = name, memoryPollMin
+		}
+		if wrappers[name] {
+			w.inheritedKB = max(w.inheritedKB, hwm)
+		} else {
+			w.peakKB = max(w.peakKB, hwm)
+		}
+		select {
+		case <-w.stop:
+			return
+		default:
+		}
+		ts := syscall.NsecToTimespec(int64(pause))
+		syscall.Nanosleep(&ts, nil)
+		pause = min(2*pause, memoryPollMax)
+	}
+}
+
+// finish stops the watch once the process has been reaped and returns its peak memory in KiB.
+func (w *memoryWatch) finish(ps *os.ProcessState) int64 {
+	if w == nil {
+		if ps == nil {
+			return 0
+		}
+		return peakMemoryKB(ps)
+	}
+	close(w.stop)
+	<-w.done
+	w.status.Close()
+	if ps == nil {
+		return 0
+	}
+	// Clearly above everything it could have inherited, ru_maxrss is the program's own peak and
+	// also covers growth after the last look.
+	if ru := peakMemoryKB(ps); ru > w.inheritedKB+rssSlackKB {
+		return max(ru, w.peakKB)
+	}
+	return w.peakKB
+}
+
+// parseProcStatus reads the command name and VmHWM, in KiB, from a /proc/<pid>/status file.
+// VmHWM is missing while a process is exiting.
+func parseProcStatus(status []byte) (string, int64) {
+	var name string
+	var hwm int64
+	for _, line := range bytes.Split(status, []byte("\n")) {
+		key, value, ok := bytes.Cut(line, []byte(":"))
+		if !ok {
+			continue
+		}
+		switch string(key) {
+		case "Name":
+			name = string(bytes.TrimSpace(value))
+		case "VmHWM":
+			hwm, _ = strconv.ParseInt(string(bytes.TrimSuffix(bytes.TrimSpace(value), []byte(" kB"))), 10, 64)
+		}
+	}
+	return name, hwm
+}
+
+// commName is the name the kernel gives a process running the executable file base.
+func commName(base string) string {
+	const taskCommLen = 16
+	if len(base) >= taskCommLen {
+		return base[:taskCommLen-1]
+	}
+	return base
+}
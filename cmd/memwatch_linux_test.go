@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestPeakMemoryLeavesOutCfr(t *testing.T) {
+	prog, err := exec.LookPath("true")
+	if err != nil {
+		t.Skip("no true command")
+	}
+	// The ulimit wrapper and the test binary itself are both larger than true ever gets.
+	for _, lim := range []runLimits{{}, {MemoryMB: 256}} {
+		for i := 0; i < 5; i++ {
+			p, err := startProgram(runSpec{Cmd: prog, Limits: lim})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res := p.wait(); res.PeakMemoryKB >= 2048 {
+				t.Errorf("true with %d MB limit peaked at %d KiB, want under 2048", lim.MemoryMB, res.PeakMemoryKB)
+			}
+		}
+	}
+}
+
+func TestParseProcStatus(t *testing.T) {
+	name, hwm := parseProcStatus([]byte("Name:\tmain\nUmask:\t0022\nVmPeak:\t   10240 kB\nVmHWM:\t    1536 kB\n"))
+	if name != "main" || hwm != 1536 {
+		t.Errorf("parseProcStatus = %q, %d, want \"main\", 1536", name, hwm)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package cmd
+
+import "os"
+
+// memoryWatch is only needed on Linux, where ru_maxrss carries cfr's own memory into the
+// programs it starts.
+type memoryWatch struct{}
+
+func watchMemory(pid int) *memoryWatch {
+	return nil
+}
+
+// finish returns the peak memory of a reaped process in KiB.
+func (w *memoryWatch) finish(ps *os.ProcessState) int64 {
+	if ps == nil {
+		return 0
+	}
+	return peakMemoryKB(ps)
+}
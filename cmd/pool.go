@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"runtime"
+	"sync"
+)
+
+// workerCount resolves the -j setting: zero or less means one worker per CPU.
+func workerCount(jobs int) int {
+	if jobs <= 0 {
+		return runtime.NumCPU()
+	}
+	return jobs
+}
+
+// runOrdered calls run(i) for every i in [0, n) on at most jobs goroutines and calls report(i)
+// in index order, each as soon as run(i) and every earlier report have finished. Once report
+// returns false no more runs are started; the ones already running finish unreported.
+func runOrdered(n, jobs int, run func(i int), report func(i int) bool) {
+	done := make([]chan struct{}, n)
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	next := make(chan int)
+	stop := make(chan struct{})
+	var workers sync.WaitGroup
+	for w := 0; w < workerCount(jobs) && w < n; w++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for i := range next {
+				run(i)
+				close(done[i])
+			}
+		}()
+	}
+	go func() {
+		defer close(next)
+		for i := 0; i < n; i++ {
+			select {
+			case next <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	for i := 0; i < n; i++ {
+		<-done[i]
+		if !report(i) {
+			close(stop)
+			break
+		}
+	}
+	workers.Wait()
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/MihaiZegheru/cfr/internal"
+)
+
+// problemContext is everything needed to build and run the programs of one loaded problem.
+type problemContext struct {
+	ID     string
+	Entry  internal.ProblemEntry
+	Config internal.Config
+	// Dir is the problem directory, named "<problemID>. <name>".
+	Dir      string
+	Language internal.Language
+	Limits   runLimits
+	// Tests are read from the problem's tests folder.
+	Tests []internal.TestCase
+	// Profile is the build profile of the solution, "" for release; Build is what it resolves to.
+	Profile string
+	Build   internal.LanguageBuild
+}
+
+// loadProblem loads the workspace state and looks problemID up in it. Its errors are meant to be
+// printed as they are.
+func loadProblem(problemID string) (internal.ProblemsState, internal.ProblemEntry, error) {
+	state, err := internal.LoadProblemsState()
+	if err != nil || state.ContestID == "" {
+		return state, internal.ProblemEntry{}, errors.New("No contest ID loaded. Please run 'cfr load <ID>' first.")
+	}
+	prob, ok := state.Problems[problemID]
+	if !ok {
+		return state, prob, fmt.Errorf("Problem %s not found in state. Please run 'cfr load <ID>' again.", problemID)
+	}
+	return state, prob, nil
+}
+
+// loadProblemContext resolves a problem from the workspace state and config. Its errors are
+// meant to be printed as they are.
+func loadProblemContext(problemID string) (*problemContext, error) {
+	_, prob, err := loadProblem(problemID)
+	if err != nil {
+		return nil, err
+	}
+	cfg, _ := internal.LoadConfig()
+	pc := &problemContext{
+		ID:      problemID,
+		Entry:   prob,
+		Config:  cfg,
+		Dir:     internal.ProblemDir(problemID, prob),
+		Limits:  problemLimits(cfg, problemID, prob),
+		Profile: solutionProfile(cfg, problemID),
+	}
+	var ok bool
+	if pc.Language, ok = cfg.Language(cfg.LanguageFor(problemID)); !ok {
+		return nil, errors.New("No valid language set in .cfr/config.json. Cannot test.")
+	}
+	if pc.Build, err = resolveBuild(cfg, pc.Profile, pc.Language); err != nil {
+		return nil, err
+	}
+	if pc.Limits.Sandbox, err = sandboxFor(cfg); err != nil {
+		return nil, err
+	}
+	// Sanitizers reserve far more address space than any memory limit.
+	pc.Limits.NoAddressLimit = sanitized(pc.Build) || pc.Language.NoAddressLimit
+	if _, err := os.Stat(pc.Dir); err != nil {
+		return nil, fmt.Errorf("Directory for problem %s not found.", problemID)
+	}
+	if pc.Tests, err = internal.LoadTests(pc.Dir, prob.Tests); err != nil {
+		return nil, fmt.Errorf("Failed to read the tests of problem %s: %v", problemID, err)
+	}
+	return pc, nil
+}
+
+// sourceFile is the path of the problem's solution, main.<ext> unless its language says otherwise.
+func (pc *problemContext) sourceFile() string {
+	return filepath.Join(pc.Dir, pc.Language.SourceFileName())
+}
+
+// buildSolution compiles the problem's main solution, reporting progress as it goes.
+func (pc *problemContext) buildSolution() (program, error) {
+	sourceFile := pc.sourceFile()
+	b, err := planBuild(pc.Config, pc.Language, sourceFile, pc.Dir, pc.ID+".exe", pc.Profile)
+	if err != nil {
+		return program{}, err
+	}
+	if b.cached() {
+		fmt.Fprintf(statusOut, "Using cached build of %s.\n", sourceFile)
+		if prog, err := b.build(); err == nil {
+			return pc.withStartup(b, prog), nil
+		}
+	}
+	if b.compiles() && pc.Profile != "" && pc.Profile != releaseProfile {
+		fmt.Fprintf(statusOut, "Compiling %s (%s profile)...\n", sourceFile, pc.Profile)
+	} else if b.compiles() {
+		fmt.Fprintf(statusOut, "Compiling %s...\n", sourceFile)
+	}
+	prog, err := b.build()
+	if err != nil {
+		if ce, ok := err.(*compileError); ok {
+			return program{}, fmt.Errorf("Compilation failed: %w\n%s", ce, ce.Output)
+		}
+		return program{}, err
+	}
+	if b.compiles() {
+		fmt.Fprintln(statusOut, "Compilation successful.")
+	}
+	return pc.withStartup(b, prog), nil
+}
+
+// withStartup measures the startup time of the solution's runtime, such as the JVM, so it can
+// be reported apart from the solution's own time.
+func (pc *problemContext) withStartup(b *buildPlan, prog program) program {
+	if prog.Startup = b.startupTime(); prog.Startup > 0 {
+		fmt.Fprintf(statusOut, "%s startup takes %d ms; it is not counted in test times.\n", pc.Language.Name, prog.Startup.Milliseconds())
+	}
+	return prog
+}
@@ -0,0 +1,90 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+)
+
+// setProcessGroup starts the child in its own process group so it and anything it spawns can be killed together.
+func setProcessGroup(c *exec.Cmd) {
+	if c.SysProcAttr == nil {
+		c.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	c.SysProcAttr.Setpgid = true
+}
+
+// killProcessGroup kills the whole process group started by setProcessGroup.
+func killProcessGroup(c *exec.Cmd) {
+	if c.Process == nil {
+		return
+	}
+	if err := syscall.Kill(-c.Process.Pid, syscall.SIGKILL); err != nil {
+		c.Process.Kill()
+	}
+}
+
+// peakMemoryKB returns the peak resident set size of a finished process in KiB.
+func peakMemoryKB(ps *os.ProcessState) int64 {
+	ru, ok := ps.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+	if runtime.GOOS == "darwin" {
+		// macOS reports ru_maxrss in bytes.
+		return int64(ru.Maxrss) / 1024
+	}
+	return int64(ru.Maxrss)
+}
+
+// cpuLimitHit reports whether the process was stopped by RLIMIT_CPU.
+func cpuLimitHit(ps *os.ProcessState) bool {
+	if ps == nil {
+		return false
+	}
+	ws, ok := ps.Sys().(syscall.WaitStatus)
+	return ok && ws.Signaled() && ws.Signal() == syscall.SIGXCPU
+}
+
+// signalNames maps the signals a crashing solution typically dies from to their usual names.
+var signalNames = map[syscall.Signal]string{
+	syscall.SIGSEGV: "SIGSEGV",
+	syscall.SIGFPE:  "SIGFPE",
+	syscall.SIGABRT: "SIGABRT",
+	syscall.SIGBUS:  "SIGBUS",
+	syscall.SIGILL:  "SIGILL",
+	syscall.SIGKILL: "SIGKILL",
+	syscall.SIGTERM: "SIGTERM",
+	syscall.SIGPIPE: "SIGPIPE",
+	syscall.SIGTRAP: "SIGTRAP",
+	syscall.SIGXCPU: "SIGXCPU",
+}
+
+// signalHints explains what usually causes a signal in competitive programming code.
+var signalHints = map[syscall.Signal]string{
+	syscall.SIGSEGV: "invalid memory access or stack overflow",
+	syscall.SIGFPE:  "division by zero",
+	syscall.SIGABRT: "abort, failed assertion or uncaught exception",
+	syscall.SIGBUS:  "misaligned or invalid memory access",
+}
+
+// describeExit returns the exit code and, if the process was killed by a signal, the signal name.
+func describeExit(ps *os.ProcessState) (int, string) {
+	ws, ok := ps.Sys().(syscall.WaitStatus)
+	if !ok || !ws.Signaled() {
+		return ps.ExitCode(), ""
+	}
+	sig := ws.Signal()
+	name, ok := signalNames[sig]
+	if !ok {
+		name = fmt.Sprintf("signal %d (%s)", int(sig), sig)
+	}
+	if hint, ok := signalHints[sig]; ok {
+		name += ": " + hint
+	}
+	return -1, name
+}
@@ -0,0 +1,59 @@
+//go:build windows
+
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// setProcessGroup starts the child in a new process group so it and anything it spawns can be killed together.
+func setProcessGroup(c *exec.Cmd) {
+	if c.SysProcAttr == nil {
+		c.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	c.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
+}
+
+// killProcessGroup kills the child and its descendants.
+func killProcessGroup(c *exec.Cmd) {
+	if c.Process == nil {
+		return
+	}
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(c.Process.Pid)).Run(); err != nil {
+		c.Process.Kill()
+	}
+}
+
+// peakMemoryKB is not available from the process state on Windows.
+func peakMemoryKB(ps *os.ProcessState) int64 {
+	return 0
+}
+
+// cpuLimitHit is always false on Windows, where no CPU rlimit is applied.
+func cpuLimitHit(ps *os.ProcessState) bool {
+	return false
+}
+
+// ntStatusNames maps the NTSTATUS exit codes of crashed processes to readable names.
+var ntStatusNames = map[uint32]string{
+	0xC0000005: "STATUS_ACCESS_VIOLATION: invalid memory access",
+	0xC00000FD: "STATUS_STACK_OVERFLOW: stack overflow",
+	0xC0000094: "STATUS_INTEGER_DIVIDE_BY_ZERO: division by zero",
+	0xC000008E: "STATUS_FLOAT_DIVIDE_BY_ZERO: division by zero",
+	0xC0000409: "STATUS_STACK_BUFFER_OVERRUN: abort or buffer overrun",
+	0xC0000017: "STATUS_NO_MEMORY: out of memory",
+	0xC000001D: "STATUS_ILLEGAL_INSTRUCTION: illegal instruction",
+}
+
+// describeExit returns the exit code and, for crash exit codes, the corresponding NTSTATUS name,
+// which plays the role of a signal on Windows.
+func describeExit(ps *os.ProcessState) (int, string) {
+	code := ps.ExitCode()
+	if name, ok := ntStatusNames[uint32(code)]; ok {
+		return code, name
+	}
+	return code, ""
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/MihaiZegheru/cfr/internal"
+)
+
+const (
+	releaseProfile = "release"
+	debugProfile   = "debug"
+)
+
+// profileName is set by --profile to pick the build profile of the solution.
+var profileName string
+
+// sanitizerFlags turn on AddressSanitizer and UndefinedBehaviorSanitizer for C and C++. Errors are
+// not recoverable, so the first report ends the run.
+var sanitizerFlags = []string{"-g", "-fsanitize=address,undefined", "-fno-sanitize-recover=all", "-fno-omit-frame-pointer"}
+
+// builtinProfiles are always available; profiles in config.json with the same name override them
+// one language at a time. Release builds use the flags each language declares.
+var builtinProfiles = map[string]internal.BuildProfile{
+	releaseProfile: {},
+	debugProfile: {
+		"cpp":  {Flags: append([]string{"-std=c++17"}, sanitizerFlags...), Defines: []string{"_GLIBCXX_DEBUG", "LOCAL"}},
+		"c":    {Flags: sanitizerFlags, Defines: []string{"LOCAL"}},
+		"go":   {Flags: []string{"-race"}},
+		"rust": {Flags: []string{"--edition=2021", "-g", "-C", "debug-assertions=on", "-C", "overflow-checks=on", "--cfg", "LOCAL"}},
+	},
+}
+
+// sanitizerEnv is added to the environment of sanitized builds: leaks are irrelevant for a
+// solution that is about to exit, while a stack trace for undefined behavior, failed assertions
+// and _GLIBCXX_DEBUG checks is not. The race detector would otherwise sleep for a second before
+// every exit.
+var sanitizerEnv = map[string]string{"ASAN_OPTIONS": "detect_leaks=0:handle_abort=1", "UBSAN_OPTIONS": "print_stacktrace=1", "GORACE": "atexit_sleep_ms=0"}
+
+// solutionProfile is the build profile for a problem's solution: --profile, then --debug, then
+// config.json.
+func solutionProfile(cfg internal.Config, problemID string) string {
+	switch {
+	case profileName != "":
+		return profileName
+	case debugBuild:
+		return debugProfile
+	}
+	return cfg.ProfileFor(problemID)
+}
+
+// resolveBuild works out how l is built under the named profile ("" means release). Fields the
+// profile leaves empty come from the built-in profile of the same name, then from the language.
+func resolveBuild(cfg internal.Config, profile string, l internal.Language) (internal.LanguageBuild, error) {
+	if profile == "" {
+		profile = releaseProfile
+	}
+	user, inUser := cfg.Profiles[profile]
+	builtin, inBuiltin := builtinProfiles[profile]
+	if !inUser && !inBuiltin {
+		return internal.LanguageBuild{}, fmt.Errorf("Unknown build profile %q. Available profiles: %s.", profile, strings.Join(profileNames(cfg), ", "))
+	}
+	lb := internal.LanguageBuild{Compiler: executableFor(cfg, l), Flags: l.Flags, Runtime: l.Runtime, RunFlags: l.RunFlags}
+	lb = mergeBuild(lb, builtin[l.Name])
+	for name, over := range user {
+		if ul, ok := cfg.Language(name); ok && ul.Name == l.Name {
+			lb = mergeBuild(lb, over)
+		}
+	}
+	return lb, nil
+}
+
+// mergeBuild overrides the fields of base that are set in over.
+func mergeBuild(base, over internal.LanguageBuild) internal.LanguageBuild {
+	if over.Compiler != "" {
+		base.Compiler = over.Compiler
+	}
+	if over.Flags != nil {
+		base.Flags = over.Flags
+	}
+	if over.Defines != nil {
+		base.Defines = over.Defines
+	}
+	if over.IncludePaths != nil {
+		base.IncludePaths = over.IncludePaths
+	}
+	if over.Runtime != "" {
+		base.Runtime = over.Runtime
+	}
+	if over.RunFlags != nil {
+		base.RunFlags = over.RunFlags
+	}
+	if over.Env != nil {
+		base.Env = over.Env
+	}
+	return base
+}
+
+// profileNames lists the built-in and configured profiles.
+func profileNames(cfg internal.Config) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, m := range []map[string]internal.BuildProfile{builtinProfiles, cfg.Profiles} {
+		for name := range m {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sanitized reports whether a build uses sanitizers or Go's race detector, which need the
+// address space left unlimited.
+func sanitized(lb internal.LanguageBuild) bool {
+	for _, f := range lb.Flags {
+		if strings.HasPrefix(f, "-fsanitize=") || f == "-race" {
+			return true
+		}
+	}
+	return false
+}
+
+// buildEnv is the environment of a build as KEY=VALUE pairs in a stable order, including the
+// sanitizer defaults unless the profile sets them itself.
+func buildEnv(lb internal.LanguageBuild) []string {
+	env := map[string]string{}
+	if sanitized(lb) {
+		for k, v := range sanitizerEnv {
+			env[k] = v
+		}
+	}
+	for k, v := range lb.Env {
+		env[k] = v
+	}
+	out := make([]string, 0, len(env))
+	for k, v := range env {
+		out = append(out, k+"="+v)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,435 @@
+package cmd
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/MihaiZegheru/cfr/internal"
+)
+
+// statusOut receives progress messages such as "Compiling ...". It is stderr when a structured
+// report is written to stdout, so the report stays parseable.
+var statusOut io.Writer = os.Stdout
+
+// structuredOutputLimit caps the outputs embedded in JSON and JUnit reports.
+const structuredOutputLimit = 4096
+
+// testRecord is the result of one test. It drives both the text report and the structured ones.
+type testRecord struct {
+	Problem string `json:"problem"`
+	Test    int    `json:"test"`
+	// Name is the test's file name in the tests folder, without extension; Kind is "sample" or
+	// "custom".
+	Name    string  `json:"name,omitempty"`
+	Kind    string  `json:"kind,omitempty"`
+	Verdict verdict `json:"verdict"`
+	Message string  `json:"message,omitempty"`
+	// TimeMs is wall time; MemoryKB is peak resident memory.
+	TimeMs     int64 `json:"time_ms"`
+	UserTimeMs int64 `json:"user_time_ms"`
+	SysTimeMs  int64 `json:"sys_time_ms"`
+	StartupMs  int64 `json:"startup_ms,omitempty"`
+	MemoryKB   int64 `json:"memory_kb"`
+	// Warnings flag usage close to the problem's limits.
+	Warnings   []string `json:"warnings,omitempty"`
+	Output     string   `json:"output"`
+	Expected   string   `json:"expected,omitempty"`
+	Stderr     string   `json:"stderr,omitempty"`
+	Transcript string   `json:"transcript,omitempty"`
+	// OutputDropped and StderrDropped count the bytes cut from a run over the output limit.
+	OutputDropped int64 `json:"output_dropped_bytes,omitempty"`
+	StderrDropped int64 `json:"stderr_dropped_bytes,omitempty"`
+}
+
+// testSummary aggregates the records of one problem, or of a whole run.
+type testSummary struct {
+	Problem   string  `json:"problem,omitempty"`
+	Total     int     `json:"total"`
+	Passed    int     `json:"passed"`
+	Verdict   verdict `json:"verdict"`
+	Message   string  `json:"message,omitempty"`
+	MaxTimeMs int64   `json:"max_time_ms"`
+	// The limits the problem's tests ran with.
+	TimeLimitMs   int64 `json:"time_limit_ms,omitempty"`
+	MemoryLimitMB int   `json:"memory_limit_mb,omitempty"`
+}
+
+func (s *testSummary) setLimits(lim runLimits) {
+	s.TimeLimitMs, s.MemoryLimitMB = lim.Time.Milliseconds(), lim.MemoryMB
+}
+
+func (s *testSummary) add(rec testRecord) {
+	s.Total++
+	if rec.Verdict == verdictOK {
+		s.Passed++
+	}
+	s.Verdict = worseVerdict(s.Verdict, rec.Verdict)
+	s.MaxTimeMs = max(s.MaxTimeMs, rec.TimeMs)
+}
+
+// problemSummaries keeps one summary per problem, in the order the problems were run.
+type problemSummaries []testSummary
+
+// start begins the summary of problemID unless it is already the current one.
+func (ps *problemSummaries) start(problemID string) *testSummary {
+	if n := len(*ps); n == 0 || (*ps)[n-1].Problem != problemID {
+		*ps = append(*ps, testSummary{Problem: problemID})
+	}
+	return &(*ps)[len(*ps)-1]
+}
+
+func (ps *problemSummaries) fail(problemID string, v verdict, msg string) {
+	s := ps.start(problemID)
+	s.Verdict = worseVerdict(s.Verdict, v)
+	s.Message = msg
+}
+
+// total merges all problem summaries into one.
+func (ps problemSummaries) total() testSummary {
+	var total testSummary
+	for _, s := range ps {
+		total.Total += s.Total
+		total.Passed += s.Passed
+		total.Verdict = worseVerdict(total.Verdict, s.Verdict)
+		total.MaxTimeMs = max(total.MaxTimeMs, s.MaxTimeMs)
+	}
+	return total
+}
+
+// testReporter receives the results of `cfr test` as they become available.
+type testReporter interface {
+	// problemStarted is called before the tests of a problem run.
+	problemStarted(problemID string, count int, interactive bool, lim runLimits)
+	// testFinished is called for every test, in test order.
+	testFinished(rec testRecord)
+	// problemFailed reports a problem whose tests could not run at all, e.g. on a compile error.
+	// v is empty when there was nothing to run.
+	problemFailed(problemID string, v verdict, msg string)
+	// finish is called once everything has run.
+	finish()
+}
+
+// newTestReporter returns the reporter for a --format value and points statusOut accordingly.
+func newTestReporter(format string) (testReporter, error) {
+	switch format {
+	case "", "text":
+		statusOut = os.Stdout
+		return &textReporter{}, nil
+	case "json", "junit":
+		statusOut = os.Stderr
+		return &structuredReporter{format: format}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q: use text, json or junit", format)
+}
+
+// textReporter prints human-readable results as they come in, and a summary table at the end
+// when several problems were tested.
+type textReporter struct {
+	summaries problemSummaries
+}
+
+func (r *textReporter) problemStarted(problemID string, count int, interactive bool, lim runLimits) {
+	r.summaries.start(problemID).setLimits(lim)
+	kind := ""
+	if interactive {
+		kind = "interactive "
+	}
+	fmt.Printf("Running %d %stest(s) (time limit %.2fs, memory limit %d MB)...\n", count, kind, lim.Time.Seconds(), lim.MemoryMB)
+}
+
+func (r *textReporter) testFinished(rec testRecord) {
+	r.summaries.start(rec.Problem).add(rec)
+	if rec.Kind == internal.TestCustom {
+		fmt.Printf("Test #%d (custom):\n", rec.Test)
+	} else {
+		fmt.Printf("Test #%d:\n", rec.Test)
+	}
+	fmt.Println("  " + verdictLine(rec))
+	for _, w := range rec.Warnings {
+		fmt.Println("  Warning: " + w)
+	}
+	switch {
+	case rec.Verdict == verdictOK || rec.Verdict == verdictExecFail:
+	case rec.Transcript != "":
+		printTranscript(rec.Transcript, "  ")
+	case rec.Kind == internal.TestCustom && rec.Expected == "":
+		// The checker judged it without an answer, so there is nothing to diff against.
+		fmt.Println("  Output:")
+		fmt.Println(strings.TrimRight(rec.Output, "\r\n"))
+	case rec.Verdict == verdictWA || rec.Verdict == verdictPE:
+		printOutputDiff(rec.Expected, rec.Output, "  ")
+	}
+	printDebugOutput(rec.Stderr, "  ")
+}
+
+// verdictLine is the verdict of a test with the resources it used and its message.
+func verdictLine(rec testRecord) string {
+	if rec.Verdict == verdictExecFail {
+		return withMessage(rec.Verdict, rec.Message)
+	}
+	ms := func(n int64) time.Duration { return time.Duration(n) * time.Millisecond }
+	usage := describeUsage(ms(rec.UserTimeMs), ms(rec.SysTimeMs), ms(rec.TimeMs), rec.MemoryKB)
+	line := fmt.Sprintf("%s (%s)", rec.Verdict, usage)
+	if rec.Message != "" {
+		line += ": " + rec.Message
+	}
+	return line
+}
+
+func (r *textReporter) problemFailed(problemID string, v verdict, msg string) {
+	r.summaries.fail(problemID, v, msg)
+	fmt.Println(msg)
+}
+
+func (r *textReporter) finish() {
+	if len(r.summaries) < 2 {
+		return
+	}
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Problem\tPassed\tVerdict\tMax time")
+	for _, s := range r.summaries {
+		v, t := string(s.Verdict), "-"
+		if v == "" {
+			v = "No tests"
+		}
+		if s.Total > 0 {
+			t = fmt.Sprintf("%.2fs", float64(s.MaxTimeMs)/1000)
+		}
+		fmt.Fprintf(w, "%s\t%d/%d\t%s\t%s\n", s.Problem, s.Passed, s.Total, v, t)
+	}
+	w.Flush()
+}
+
+// structuredReporter collects every record and writes a JSON or JUnit XML report at the end.
+type structuredReporter struct {
+	format    string
+	records   []testRecord
+	summaries problemSummaries
+}
+
+func (r *structuredReporter) problemStarted(problemID string, count int, interactive bool, lim runLimits) {
+	r.summaries.start(problemID).setLimits(lim)
+}
+
+func (r *structuredReporter) testFinished(rec testRecord) {
+	r.records = append(r.records, rec)
+	r.summaries.start(rec.Problem).add(rec)
+}
+
+func (r *structuredReporter) problemFailed(problemID string, v verdict, msg string) {
+	fmt.Fprintln(statusOut, msg)
+	r.summaries.fail(problemID, v, msg)
+}
+
+func (r *structuredReporter) finish() {
+	records := make([]testRecord, len(r.records))
+	for i, rec := range r.records {
+		rec.Output = truncateOutput(rec.Output, rec.OutputDropped)
+		rec.Expected = truncateOutput(rec.Expected, 0)
+		rec.Stderr = truncateOutput(rec.Stderr, rec.StderrDropped)
+		rec.Transcript = truncateTranscript(rec.Transcript)
+		records[i] = rec
+	}
+	if r.format == "junit" {
+		writeJUnit(os.Stdout, records, r.summaries)
+		return
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(struct {
+		Tests    []testRecord  `json:"tests"`
+		Problems []testSummary `json:"problems"`
+		Summary  testSummary   `json:"summary"`
+	}{records, r.summaries, r.summaries.total()})
+}
+
+// truncateOutput shortens s to structuredOutputLimit bytes, noting how much was cut. dropped is
+// how much the run already cut from s after an Output Limit Exceeded, noted at its end.
+func truncateOutput(s string, dropped int64) string {
+	if dropped > 0 {
+		s = strings.TrimSuffix(s, overflowNote(dropped))
+	}
+	if len(s) <= structuredOutputLimit {
+		if dropped > 0 {
+			return s + overflowNote(dropped)
+		}
+		return s
+	}
+	dropped += int64(len(s) - structuredOutputLimit)
+	return s[:structuredOutputLimit] + fmt.Sprintf("\n... (%d more bytes)", dropped)
+}
+
+// truncateTranscript keeps the end of a long transcript, where interactions usually go wrong.
+func truncateTranscript(s string) string {
+	if len(s) <= structuredOutputLimit {
+		return s
+	}
+	return fmt.Sprintf("... (%d earlier bytes)\n", len(s)-structuredOutputLimit) + s[len(s)-structuredOutputLimit:]
+}
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string `xml:"name,attr"`
+	ClassName string `xml:"classname,attr"`
+	Time      string `xml:"time,attr"`
+	// Properties carry the resource usage, which JUnit has no attributes for.
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Failure    *junitProblem   `xml:"failure,omitempty"`
+	Error      *junitProblem   `xml:"error,omitempty"`
+	SystemOut  string          `xml:"system-out,omitempty"`
+	SystemErr  string          `xml:"system-err,omitempty"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// writeJUnit writes one <testsuite> per problem. Wrong answers are failures; crashes, limits and
+// compile errors are errors.
+func writeJUnit(w io.Writer, records []testRecord, summaries problemSummaries) {
+	var out junitSuites
+	for _, s := range summaries {
+		suite := junitSuite{Name: s.Problem}
+		var totalMs int64
+		for _, rec := range records {
+			if rec.Problem != s.Problem {
+				continue
+			}
+			totalMs += rec.TimeMs
+			c := junitCase{
+				Name:      fmt.Sprintf("Test #%d", rec.Test),
+				ClassName: "cfr." + rec.Problem,
+				Time:      fmt.Sprintf("%.3f", float64(rec.TimeMs)/1000),
+				SystemOut: rec.Output,
+				SystemErr: rec.Stderr,
+			}
+			if rec.Verdict != verdictExecFail {
+				c.Properties = []junitProperty{
+					{"user_time_ms", fmt.Sprint(rec.UserTimeMs)},
+					{"sys_time_ms", fmt.Sprint(rec.SysTimeMs)},
+					{"wall_time_ms", fmt.Sprint(rec.TimeMs)},
+					{"memory_kb", fmt.Sprint(rec.MemoryKB)},
+				}
+				for _, w := range rec.Warnings {
+					c.Properties = append(c.Properties, junitProperty{"warning", w})
+				}
+			}
+			p := &junitProblem{Message: withMessage(rec.Verdict, rec.Message), Type: string(rec.Verdict)}
+			switch rec.Verdict {
+			case verdictOK:
+			case verdictWA, verdictPE:
+				p.Body = "Expected:\n" + rec.Expected + "\nFound:\n" + rec.Output
+				if rec.Transcript != "" {
+					p.Body = rec.Transcript
+				}
+				c.Failure = p
+				suite.Failures++
+			default:
+				p.Body = rec.Transcript
+				c.Error = p
+				suite.Errors++
+			}
+			suite.Cases = append(suite.Cases, c)
+		}
+		if s.Total == 0 && s.Verdict != "" {
+			// The problem never got to run its tests.
+			suite.Cases = append(suite.Cases, junitCase{
+				Name:      "build",
+				ClassName: "cfr." + s.Problem,
+				Time:      "0.000",
+				Error:     &junitProblem{Message: string(s.Verdict), Type: string(s.Verdict), Body: s.Message},
+			})
+			suite.Errors++
+		}
+		suite.Tests = len(suite.Cases)
+		suite.Time = fmt.Sprintf("%.3f", float64(totalMs)/1000)
+		out.Suites = append(out.Suites, suite)
+	}
+	io.WriteString(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	enc.Encode(out)
+	io.WriteString(w, "\n")
+}
+
+// outcomeRecord turns the outcome of a regular test into its report record.
+func outcomeRecord(problemID string, i int, tc internal.TestCase, o testOutcome, lim runLimits) testRecord {
+	rec := testRecord{
+		Problem:       problemID,
+		Test:          i + 1,
+		Name:          tc.Name,
+		Kind:          tc.TestKind(),
+		Verdict:       o.Verdict,
+		Message:       o.Message,
+		TimeMs:        o.Res.Elapsed.Milliseconds(),
+		UserTimeMs:    o.Res.UserTime.Milliseconds(),
+		SysTimeMs:     o.Res.SysTime.Milliseconds(),
+		StartupMs:     o.Res.Startup.Milliseconds(),
+		MemoryKB:      o.Res.PeakMemoryKB,
+		Output:        o.Res.Stdout,
+		Expected:      tc.Output,
+		Stderr:        o.Res.Stderr,
+		OutputDropped: o.Res.StdoutDropped,
+		StderrDropped: o.Res.StderrDropped,
+	}
+	switch {
+	case o.Err != nil:
+		rec.Verdict, rec.Message = verdictExecFail, o.Err.Error()
+		return rec
+	case o.Res.Verdict != "":
+		rec.Message = runFailureMessage(o.Res, lim)
+	}
+	_, rec.Warnings = resultUsage(o.Res, lim)
+	return rec
+}
+
+// interactionRecord turns the outcome of an interactive test into its report record.
+func interactionRecord(problemID string, i int, it interaction, err error, lim runLimits) testRecord {
+	rec := testRecord{
+		Problem:       problemID,
+		Test:          i + 1,
+		Verdict:       it.Verdict,
+		Message:       interactionMessage(it, lim),
+		TimeMs:        it.Solution.Elapsed.Milliseconds(),
+		UserTimeMs:    it.Solution.UserTime.Milliseconds(),
+		SysTimeMs:     it.Solution.SysTime.Milliseconds(),
+		StartupMs:     it.Solution.Startup.Milliseconds(),
+		MemoryKB:      it.Solution.PeakMemoryKB,
+		Stderr:        it.Solution.Stderr,
+		Transcript:    it.Transcript,
+		StderrDropped: it.Solution.StderrDropped,
+	}
+	if err != nil {
+		rec.Verdict, rec.Message = verdictExecFail, err.Error()
+		return rec
+	}
+	_, rec.Warnings = resultUsage(it.Solution, lim)
+	return rec
+}
@@ -1,52 +1,400 @@
 package cmd
 
 import (
-       "bytes"
-       "os"
-       "os/exec"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 // runAndCapture runs a command and returns its combined output and error
 func runAndCapture(cmd string, args ...string) (string, error) {
-       c := exec.Command(cmd, args...)
-       var out bytes.Buffer
-       c.Stdout = &out
-       c.Stderr = &out
-       err := c.Run()
-       return out.String(), err
-}
-
-// runWithInput runs a command, feeds it the contents of inputFile as stdin, and returns its stdout
-func runWithInput(cmd string, args []string, inputFile string) (string, error) {
-       c := exec.Command(cmd, args...)
-       in, err := os.Open(inputFile)
-       if err != nil {
-               return "", err
-       }
-       defer in.Close()
-       c.Stdin = in
-       var out bytes.Buffer
-       c.Stdout = &out
-       c.Stderr = &out
-       err = c.Run()
-       return out.String(), err
-}
-
-// runWithInputCwd runs a command, feeds it the contents of inputFile as stdin, sets the working directory, and returns its stdout
-func runWithInputCwd(cmd string, args []string, inputFile string, cwd string) (string, error) {
-       c := exec.Command(cmd, args...)
-       if cwd != "" {
-               c.Dir = cwd
-       }
-       in, err := os.Open(inputFile)
-       if err != nil {
-               return "", err
-       }
-       defer in.Close()
-       c.Stdin = in
-       var out bytes.Buffer
-       c.Stdout = &out
-       c.Stderr = &out
-       err = c.Run()
-       return out.String(), err
-}
\ No newline at end of file
+	return runAndCaptureEnv(nil, cmd, args...)
+}
+
+// runAndCaptureEnv is runAndCapture with env added to cfr's own environment.
+func runAndCaptureEnv(env []string, cmd string, args ...string) (string, error) {
+	c := exec.Command(cmd, args...)
+	if len(env) > 0 {
+		c.Env = append(os.Environ(), env...)
+	}
+	var out bytes.Buffer
+	c.Stdout = &out
+	c.Stderr = &out
+	err := c.Run()
+	return out.String(), err
+}
+
+// runLimits are the resource limits applied to a single run. Zero values mean unlimited.
+type runLimits struct {
+	Time     time.Duration
+	MemoryMB int
+	// NoAddressLimit keeps the memory limit off the address space, for sanitizer builds and
+	// runtimes such as Go's that reserve far more of it than they use; peak memory is still
+	// checked after the run.
+	NoAddressLimit bool
+	// OutputMB caps what the program may write to stdout and stderr together.
+	OutputMB int
+	// Sandbox isolates the program, see sandboxMode.
+	Sandbox sandboxMode
+}
+
+// runResult describes how a single limited run ended.
+type runResult struct {
+	Stdout       string
+	Stderr       string
+	Elapsed      time.Duration
+	PeakMemoryKB int64
+	// UserTime and SysTime are the CPU time the program spent in user and kernel mode.
+	UserTime, SysTime time.Duration
+	// Startup is the runtime startup time that was left out of Elapsed.
+	Startup time.Duration
+	// Exited is when the program was reaped, for telling which of two programs ended first.
+	Exited time.Time
+	// Verdict is set when the run itself failed (TLE, MLE, OLE or RE) and is empty when the
+	// program exited normally and its output still has to be checked.
+	Verdict verdict
+	// ExitCode and Signal describe an abnormal exit; Signal is e.g. "SIGSEGV".
+	ExitCode int
+	Signal   string
+	// StdoutDropped and StderrDropped count the bytes cut after an Output Limit Exceeded, which
+	// Stdout and Stderr then end with a note about.
+	StdoutDropped, StderrDropped int64
+}
+
+// exitDescription names the signal or exit code behind a Runtime Error.
+func (r runResult) exitDescription() string {
+	if r.Signal != "" {
+		return "terminated by " + r.Signal
+	}
+	return fmt.Sprintf("exit code %d", r.ExitCode)
+}
+
+// allocFailureMarkers are what common runtimes print when an allocation fails under RLIMIT_AS.
+var allocFailureMarkers = []string{
+	"std::bad_alloc",
+	"MemoryError",
+	"out of memory",
+	"Cannot allocate memory",
+	"OutOfMemoryError",
+}
+
+// runSpec describes a program started by startProgram.
+type runSpec struct {
+	Cmd  string
+	Args []string
+	Dir  string
+	// Env is added to cfr's own environment.
+	Env    []string
+	Limits runLimits
+	// Startup is how long the runtime takes to start: the time limit applies on top of it, and
+	// it is subtracted from the result's Elapsed.
+	Startup time.Duration
+	// Stdin feeds the program. Stdout, if set, receives its output instead of runResult.Stdout.
+	Stdin  io.Reader
+	Stdout io.Writer
+}
+
+// runningProgram is a started program whose limits are being enforced.
+type runningProgram struct {
+	c        *exec.Cmd
+	lim      runLimits
+	startup  time.Duration
+	start    time.Time
+	timer    *time.Timer
+	timedOut atomic.Bool
+	output   *outputCap
+	mem      *memoryWatch
+	// cleanup removes the sandbox directory once the program is done.
+	cleanup func()
+	stdout  cappedBuffer
+	stderr  cappedBuffer
+}
+
+// outputCap is the output budget shared by a program's stdout and stderr. Once it is used up
+// the program is killed, and anything it still writes is thrown away.
+type outputCap struct {
+	left     atomic.Int64
+	exceeded atomic.Bool
+	kill     func()
+}
+
+// take charges n bytes of output and returns how many of them fit in the budget. The program is
+// killed the first time the budget runs out.
+func (c *outputCap) take(n int) int {
+	if c.exceeded.Load() {
+		return 0
+	}
+	left := c.left.Add(-int64(n))
+	if left >= 0 {
+		return n
+	}
+	if !c.exceeded.Swap(true) {
+		c.kill()
+	}
+	return int(max(int64(n)+left, 0))
+}
+
+// cappedBuffer collects output until its outputCap, if any, runs out.
+type cappedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	cap *outputCap
+	// written counts everything the program wrote, kept or not.
+	written int64
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := len(p)
+	b.written += int64(n)
+	if b.cap != nil {
+		p = p[:b.cap.take(n)]
+	}
+	b.buf.Write(p)
+	return n, nil
+}
+
+func (b *cappedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// overflowKeepBytes is how much of each stream is kept after an Output Limit Exceeded; nobody
+// reads megabytes of runaway output.
+const overflowKeepBytes = 64 << 10
+
+// clipped returns the start of the output with a note on how much of it was cut, and that count.
+func (b *cappedBuffer) clipped() (string, int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	kept := b.buf.Bytes()[:min(b.buf.Len(), overflowKeepBytes)]
+	dropped := b.written - int64(len(kept))
+	if dropped == 0 {
+		return string(kept), 0
+	}
+	return string(kept) + overflowNote(dropped), dropped
+}
+
+// overflowNote ends output that was cut after an Output Limit Exceeded.
+func overflowNote(dropped int64) string {
+	return fmt.Sprintf("\n... (%d more bytes dropped after the output limit)", dropped)
+}
+
+// startProgram starts spec in its own process group with stdout and stderr kept apart. The whole
+// group is killed once spec.Limits.Time of wall time has elapsed, and memory is capped via
+// wrapLimited where supported.
+func startProgram(spec runSpec) (*runningProgram, error) {
+	if runsAborted.Load() {
+		return nil, errRunAborted
+	}
+	p := &runningProgram{lim: spec.Limits, startup: spec.Startup}
+	lim := spec.Limits
+	if lim.Time > 0 {
+		lim.Time += spec.Startup
+	}
+	cmd, args := wrapLimited(spec.Cmd, spec.Args, lim)
+	c := exec.Command(cmd, args...)
+	c.Dir = spec.Dir
+	if spec.Limits.Sandbox != sandboxOff {
+		dir, err := makeSandboxDir(spec.Dir)
+		if err != nil {
+			return nil, err
+		}
+		p.cleanup = func() { os.RemoveAll(dir) }
+		c.Dir = dir
+	}
+	if len(spec.Env) > 0 {
+		c.Env = append(os.Environ(), spec.Env...)
+	}
+	if spec.Limits.OutputMB > 0 {
+		p.output = &outputCap{kill: func() { killProcessGroup(c) }}
+		p.output.left.Store(int64(spec.Limits.OutputMB) << 20)
+		p.stdout.cap, p.stderr.cap = p.output, p.output
+	}
+	c.Stdin = spec.Stdin
+	c.Stdout = &p.stdout
+	if spec.Stdout != nil {
+		c.Stdout = spec.Stdout
+	}
+	c.Stderr = &p.stderr
+	setProcessGroup(c)
+	if spec.Limits.Sandbox != sandboxOff {
+		isolate(c, spec.Limits.Sandbox)
+	}
+	// Don't hang on descendants that inherited our pipes after the group was killed.
+	c.WaitDelay = time.Second
+	p.c = c
+	p.start = time.Now()
+	if err := c.Start(); err != nil {
+		if p.cleanup != nil {
+			p.cleanup()
+		}
+		return nil, err
+	}
+	trackRunning(c)
+	p.mem = watchMemory(c.Process.Pid)
+	if lim.Time > 0 {
+		p.timer = time.AfterFunc(lim.Time, func() {
+			p.timedOut.Store(true)
+			killProcessGroup(c)
+		})
+	}
+	return p, nil
+}
+
+// kill stops the program and everything it spawned; wait still has to be called.
+func (p *runningProgram) kill() {
+	killProcessGroup(p.c)
+}
+
+// takeOutput charges n bytes the program wrote somewhere other than its own buffers, such as a
+// pipe cfr relays, and returns how many of them fit in its output limit.
+func (p *runningProgram) takeOutput(n int) int {
+	if p.output == nil {
+		return n
+	}
+	return p.output.take(n)
+}
+
+// wait waits for the program to finish and judges how it ended. TLE, MLE, OLE and crashes are
+// reported through the result's Verdict.
+func (p *runningProgram) wait() runResult {
+	var res runResult
+	p.c.Wait()
+	res.Exited = time.Now()
+	res.Elapsed = max(res.Exited.Sub(p.start)-p.startup, 0)
+	res.Startup = p.startup
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	untrackRunning(p.c)
+	res.Stdout = p.stdout.String()
+	res.Stderr = p.stderr.String()
+	// Reap anything the program left running in its group.
+	killProcessGroup(p.c)
+	if p.cleanup != nil {
+		p.cleanup()
+	}
+	ps := p.c.ProcessState
+	res.PeakMemoryKB = p.mem.finish(ps)
+	if ps == nil {
+		return res
+	}
+	res.UserTime, res.SysTime = ps.UserTime(), ps.SystemTime()
+	res.ExitCode, res.Signal = describeExit(ps)
+	switch {
+	case p.output != nil && p.output.exceeded.Load():
+		res.Verdict = verdictOLE
+		res.Stdout, res.StdoutDropped = p.stdout.clipped()
+		res.Stderr, res.StderrDropped = p.stderr.clipped()
+	case p.lim.MemoryMB > 0 && exceededMemory(res, p.lim.MemoryMB):
+		res.Verdict = verdictMLE
+	case p.timedOut.Load() || cpuLimitHit(ps):
+		res.Verdict = verdictTLE
+	case !ps.Success():
+		res.Verdict = verdictRE
+		if _, ok := parseSanitizerReport(res.Stderr); ok {
+			res.Verdict = verdictSanitizer
+		}
+	}
+	return res
+}
+
+// runWithLimit runs a command in cwd with the contents of inputFile as stdin, under the limits
+// in lim. TLE, MLE and crashes are reported through the result's Verdict; the error is only set
+// when the input could not be opened or the program could not be started.
+func runWithLimit(cmd string, args []string, inputFile string, cwd string, lim runLimits) (runResult, error) {
+	in, err := os.Open(inputFile)
+	if err != nil {
+		return runResult{}, err
+	}
+	defer in.Close()
+	p, err := startProgram(runSpec{Cmd: cmd, Args: args, Dir: cwd, Limits: lim, Stdin: in})
+	if err != nil {
+		return runResult{}, err
+	}
+	return p.wait(), nil
+}
+
+// exceededMemory decides whether a run should be judged Memory Limit Exceeded: either its peak
+// RSS went over the limit, or it failed while reporting an allocation failure.
+func exceededMemory(res runResult, limitMB int) bool {
+	if res.PeakMemoryKB > int64(limitMB)*1024 {
+		return true
+	}
+	if res.ExitCode == 0 && res.Signal == "" {
+		return false
+	}
+	for _, marker := range allocFailureMarkers {
+		if strings.Contains(res.Stderr, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	runningMu     sync.Mutex
+	running       = map[*exec.Cmd]struct{}{}
+	interruptOnce sync.Once
+	runsAborted   atomic.Bool
+)
+
+// errRunAborted is returned by startProgram between abortRunning and resumeRunning.
+var errRunAborted = errors.New("run aborted")
+
+// trackRunning records a started process group so it is killed if cfr itself is interrupted;
+// children live in their own group and would otherwise miss the terminal's Ctrl-C.
+func trackRunning(c *exec.Cmd) {
+	interruptOnce.Do(func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-sig
+			runningMu.Lock()
+			for c := range running {
+				killProcessGroup(c)
+			}
+			runningMu.Unlock()
+			os.Exit(130)
+		}()
+	})
+	runningMu.Lock()
+	running[c] = struct{}{}
+	if runsAborted.Load() {
+		killProcessGroup(c)
+	}
+	runningMu.Unlock()
+}
+
+func untrackRunning(c *exec.Cmd) {
+	runningMu.Lock()
+	delete(running, c)
+	runningMu.Unlock()
+}
+
+// abortRunning kills every running program and makes startProgram fail until resumeRunning is
+// called, so an in-flight test run winds down quickly.
+func abortRunning() {
+	runningMu.Lock()
+	runsAborted.Store(true)
+	for c := range running {
+		killProcessGroup(c)
+	}
+	runningMu.Unlock()
+}
+
+func resumeRunning() {
+	runsAborted.Store(false)
+}
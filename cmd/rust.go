@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/MihaiZegheru/cfr/internal"
+)
+
+// cargoManifest in the workspace root makes Rust solutions build through cargo, so they can use
+// the crates it vendors.
+const cargoManifest = "Cargo.toml"
+
+// usesCargo reports whether Rust solutions are built through the workspace's cargo project.
+func usesCargo(l internal.Language) bool {
+	return l.Name == "rust" && fileExists(cargoManifest)
+}
+
+// planCargoBuild builds a Rust source as a binary target of the workspace's cargo project: it is
+// copied to src/bin/cfr-<name>.rs for the build only and built offline in release mode, so crates
+// must be vendored. Cargo keeps its own incremental cache, so the build cache is not used.
+func planCargoBuild(l internal.Language, lb internal.LanguageBuild, sourceFile, dir, binName string) *buildPlan {
+	target := "cfr-" + strings.TrimSuffix(binName, ".exe")
+	targetDir := os.Getenv("CARGO_TARGET_DIR")
+	if targetDir == "" {
+		targetDir = "target"
+	}
+	artifact := filepath.Join(targetDir, "release", target)
+	if runtime.GOOS == "windows" {
+		artifact += ".exe"
+	}
+	b := &buildPlan{
+		dir:           dir,
+		binName:       binName,
+		env:           cargoEnv(l, lb),
+		sourceFile:    sourceFile,
+		compileSource: filepath.Join("src", "bin", target+".rs"),
+		dropCopy:      true,
+		artifact:      artifact,
+		compiler:      "cargo",
+		args:          []string{"build", "--release", "--offline", "--quiet", "--color", "never", "--bin", target},
+		diagnostics:   tidyRustDiagnostics,
+	}
+	b.run = program{Cmd: "." + string(os.PathSeparator) + binName, Dir: dir, Env: b.env}
+	return b
+}
+
+// cargoEnv is the environment of a cargo build: the build profile's rustc flags are passed in
+// CARGO_ENCODED_RUSTFLAGS after any RUSTFLAGS already set, and a compiler other than the
+// language's default in RUSTC. The edition and optimization level are left to Cargo.toml and
+// --release, since rustc rejects a second edition.
+func cargoEnv(l internal.Language, lb internal.LanguageBuild) []string {
+	env := buildEnv(lb)
+	if _, ok := lb.Env["RUSTFLAGS"]; !ok {
+		if _, ok := lb.Env["CARGO_ENCODED_RUSTFLAGS"]; !ok {
+			flags := append(strings.Fields(os.Getenv("RUSTFLAGS")), cargoRustFlags(lb.Flags)...)
+			if len(flags) > 0 {
+				env = append(env, "CARGO_ENCODED_RUSTFLAGS="+strings.Join(flags, "\x1f"))
+			}
+		}
+	}
+	if _, ok := lb.Env["RUSTC"]; !ok && lb.Compiler != "" && lb.Compiler != l.Compiler {
+		env = append(env, "RUSTC="+lb.Compiler)
+	}
+	return env
+}
+
+// cargoRustFlags drops the rustc flags that cargo sets itself from flags.
+func cargoRustFlags(flags []string) []string {
+	var out []string
+	for i := 0; i < len(flags); i++ {
+		f := flags[i]
+		switch {
+		case f == "-O" || strings.HasPrefix(f, "--edition=") || strings.HasPrefix(f, "-Copt-level"):
+		case f == "--edition":
+			i++
+		case f == "-C" && i+1 < len(flags) && strings.HasPrefix(flags[i+1], "opt-level"):
+			i++
+		default:
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// rustNoise are the lines rustc and cargo add after the actual errors.
+var rustNoise = []string{
+	"error: aborting due to",
+	"error: could not compile",
+	"For more information about",
+	"Some errors have detailed explanations",
+}
+
+// tidyRustDiagnostics keeps only the errors of rustc or cargo output: warnings, which can bury
+// the errors, and the summary lines after them are dropped.
+func tidyRustDiagnostics(out string) string {
+	var kept []string
+	for _, block := range strings.Split(strings.ReplaceAll(out, "\r\n", "\n"), "\n\n") {
+		if strings.HasPrefix(strings.TrimSpace(block), "warning") {
+			continue
+		}
+		var lines []string
+		for _, line := range strings.Split(block, "\n") {
+			noise := false
+			for _, n := range rustNoise {
+				noise = noise || strings.HasPrefix(line, n)
+			}
+			if !noise {
+				lines = append(lines, line)
+			}
+		}
+		if block = strings.TrimRight(strings.Join(lines, "\n"), "\n"); strings.TrimSpace(block) != "" {
+			kept = append(kept, block)
+		}
+	}
+	if len(kept) == 0 {
+		return out
+	}
+	return strings.Join(kept, "\n\n") + "\n"
+}
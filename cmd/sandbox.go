@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/MihaiZegheru/cfr/internal"
+	"github.com/spf13/cobra"
+)
+
+// sandboxMode says how solutions and generators are isolated from the machine.
+type sandboxMode string
+
+const (
+	sandboxOff sandboxMode = ""
+	// sandboxOn runs each program in a throwaway copy of its directory and, on Linux, in private
+	// user, mount and network namespaces with the rest of the filesystem read-only.
+	sandboxOn sandboxMode = "on"
+	// sandboxSeccomp adds a seccomp filter that denies sockets, ptrace, mounts, namespaces and
+	// other system calls a solution has no use for.
+	sandboxSeccomp sandboxMode = "seccomp"
+)
+
+// sandboxFlag is set by --sandbox; it overrides "sandbox" in config.json.
+var sandboxFlag string
+
+// sandboxFor resolves the sandbox mode from --sandbox and config.json.
+func sandboxFor(cfg internal.Config) (sandboxMode, error) {
+	mode := cfg.Sandbox
+	if sandboxFlag != "" {
+		mode = sandboxFlag
+	}
+	switch mode {
+	case "", "off":
+		return sandboxOff, nil
+	case "on":
+		return sandboxOn, nil
+	case "seccomp":
+		return sandboxSeccomp, nil
+	}
+	return sandboxOff, fmt.Errorf("Unknown sandbox mode %q. Use off, on or seccomp.", mode)
+}
+
+// addSandboxFlag adds --sandbox to a command that runs solutions; a bare --sandbox means "on".
+func addSandboxFlag(c *cobra.Command) {
+	c.Flags().StringVar(&sandboxFlag, "sandbox", "", "Isolate solutions and generators: off, on or seccomp (Linux namespaces; default from config.json)")
+	c.Flags().Lookup("sandbox").NoOptDefVal = string(sandboxOn)
+}
+
+var sandboxWarning sync.Once
+
+// warnSandbox tells the user once per run of cfr that the sandbox is weaker than asked for.
+func warnSandbox(format string, args ...any) {
+	sandboxWarning.Do(func() {
+		fmt.Fprintf(os.Stderr, "Warning: "+format+"\n", args...)
+	})
+}
+
+// makeSandboxDir creates a throwaway working directory holding a copy of dir, so a program can
+// read its files but whatever it writes or deletes is gone after the run. Old source versions
+// and the tests, which reach the program on stdin, are left out.
+func makeSandboxDir(dir string) (string, error) {
+	tmp, err := os.MkdirTemp("", "cfr-sandbox-")
+	if err != nil {
+		return "", err
+	}
+	if dir == "" {
+		return tmp, nil
+	}
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		target := filepath.Join(tmp, rel)
+		switch {
+		case d.IsDir() && (rel == "versions" || rel == internal.TestsDir):
+			return filepath.SkipDir
+		case d.IsDir():
+			return os.MkdirAll(target, 0755)
+		case d.Type().IsRegular():
+			return copyFile(path, target)
+		}
+		return nil
+	})
+	if err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+	return tmp, nil
+}
+
+// copyFile copies src to dst, keeping its permissions.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,265 @@
+//go:build linux
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/spf13/cobra"
+)
+
+// sandboxHelper is the hidden command cfr runs itself as inside the new namespaces. It locks the
+// filesystem down and then execs the program in its place, so pid, limits and resource usage
+// carry over to it.
+const sandboxHelper = "__sandbox"
+
+var (
+	namespacesOnce sync.Once
+	namespacesErr  error
+)
+
+// isolate makes c start in private user, mount, network, IPC and UTS namespaces through the
+// sandbox helper. Without unprivileged user namespaces it falls back to the throwaway working
+// directory alone.
+func isolate(c *exec.Cmd, mode sandboxMode) {
+	if err := probeNamespaces(); err != nil {
+		warnSandbox("user namespaces are not available (%v); programs only get a throwaway working directory.", err)
+		return
+	}
+	if mode == sandboxSeccomp && seccompDenied[runtime.GOARCH] == nil {
+		warnSandbox("the seccomp filter is not available on %s; sandboxing without it.", runtime.GOARCH)
+		mode = sandboxOn
+	}
+	self, _ := os.Executable()
+	c.Args = append([]string{self, sandboxHelper, string(mode), "--", c.Path}, c.Args[1:]...)
+	c.Path = self
+	setNamespaces(c)
+}
+
+func setNamespaces(c *exec.Cmd) {
+	if c.SysProcAttr == nil {
+		c.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	c.SysProcAttr.Cloneflags = syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWNET | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS
+	// Root inside the namespace, which is needed to set up the mounts, is the user outside it.
+	c.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}}
+	c.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}}
+	c.SysProcAttr.GidMappingsEnableSetgroups = false
+}
+
+// probeNamespaces checks once whether the sandbox helper can set itself up, e.g. whether the
+// kernel allows unprivileged user namespaces.
+func probeNamespaces() error {
+	namespacesOnce.Do(func() {
+		self, err := os.Executable()
+		if err != nil {
+			namespacesErr = err
+			return
+		}
+		dir, err := os.MkdirTemp("", "cfr-sandbox-")
+		if err != nil {
+			namespacesErr = err
+			return
+		}
+		defer os.RemoveAll(dir)
+		c := exec.Command(self, sandboxHelper, string(sandboxOn), "--", "/bin/sh", "-c", "exit 0")
+		c.Dir = dir
+		setNamespaces(c)
+		if out, err := c.CombinedOutput(); err != nil {
+			namespacesErr = err
+			if msg := strings.TrimSpace(string(out)); msg != "" {
+				namespacesErr = fmt.Errorf("%s", msg)
+			}
+		}
+	})
+	return namespacesErr
+}
+
+var sandboxExecCmd = &cobra.Command{
+	Use:                sandboxHelper + " <mode> -- <program> [args...]",
+	Hidden:             true,
+	DisableFlagParsing: true,
+	Args:               cobra.MinimumNArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := enterSandbox(sandboxMode(args[0]), args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "cfr sandbox: %v\n", err)
+			os.Exit(126)
+		}
+	},
+}
+
+// enterSandbox runs inside the new namespaces: it leaves only the working directory writable,
+// drops every capability, optionally installs the seccomp filter and execs argv.
+func enterSandbox(mode sandboxMode, argv []string) error {
+	// Capabilities, no_new_privs and seccomp are per thread; they must be set on the one that execs.
+	runtime.LockOSThread()
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if err := syscall.Mount("none", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("making mounts private: %v", err)
+	}
+	if err := syscall.Mount(wd, wd, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("binding the working directory: %v", err)
+	}
+	// Step onto the new mount; the old working directory is about to become read-only.
+	if err := os.Chdir(wd); err != nil {
+		return err
+	}
+	if err := readOnlyMounts(wd); err != nil {
+		return err
+	}
+	if err := dropCapabilities(); err != nil {
+		return fmt.Errorf("dropping capabilities: %v", err)
+	}
+	const prSetNoNewPrivs = 38
+	if _, _, e := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); e != 0 {
+		return fmt.Errorf("setting no_new_privs: %v", e)
+	}
+	if mode == sandboxSeccomp {
+		if err := installSeccomp(); err != nil {
+			return fmt.Errorf("installing the seccomp filter: %v", err)
+		}
+	}
+	return syscall.Exec(argv[0], argv, os.Environ())
+}
+
+// mountFlags maps the per-mount options of /proc/self/mountinfo to the flags a read-only
+// remount has to keep; dropping them is not allowed inside a user namespace.
+var mountFlags = map[string]uintptr{
+	"nosuid":     syscall.MS_NOSUID,
+	"nodev":      syscall.MS_NODEV,
+	"noexec":     syscall.MS_NOEXEC,
+	"noatime":    syscall.MS_NOATIME,
+	"nodiratime": syscall.MS_NODIRATIME,
+	"relatime":   syscall.MS_RELATIME,
+}
+
+// readOnlyMounts remounts everything read-only except the working directory and the kernel's
+// /proc, /sys and /dev. Only a failure on / is fatal; other mounts are locked down as far as the
+// kernel allows.
+func readOnlyMounts(wd string) error {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	under := func(path, dir string) bool {
+		return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, "/")+"/")
+	}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		mp := unescapeMountPath(fields[4])
+		if under(mp, wd) || under(mp, "/proc") || under(mp, "/sys") || under(mp, "/dev") {
+			continue
+		}
+		flags := uintptr(syscall.MS_REMOUNT | syscall.MS_BIND | syscall.MS_RDONLY)
+		for _, opt := range strings.Split(fields[5], ",") {
+			flags |= mountFlags[opt]
+		}
+		if err := syscall.Mount("none", mp, "", flags, ""); err != nil && mp == "/" {
+			return fmt.Errorf("making / read-only: %v", err)
+		}
+	}
+	return sc.Err()
+}
+
+// unescapeMountPath decodes the octal escapes, such as \040 for a space, of a mountinfo path.
+func unescapeMountPath(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// dropCapabilities empties the bounding set, so the program gets no capabilities from running
+// as root inside the namespace and cannot undo the read-only mounts.
+func dropCapabilities() error {
+	for c := 0; c < 64; c++ {
+		_, _, e := syscall.RawSyscall(syscall.SYS_PRCTL, syscall.PR_CAPBSET_DROP, uintptr(c), 0)
+		if e == syscall.EINVAL {
+			// Past the last capability the kernel knows.
+			return nil
+		}
+		if e != 0 {
+			return e
+		}
+	}
+	return nil
+}
+
+// seccompArch is the AUDIT_ARCH value a filter checks before trusting syscall numbers.
+var seccompArch = map[string]uint32{
+	"amd64": 0xc000003e,
+	"arm64": 0xc00000b7,
+}
+
+// seccompDenied are the system calls the seccomp filter fails with EPERM: sockets, debugging
+// other processes, mounts and namespaces, keyrings, BPF, perf, kernel modules, reboot and swap.
+var seccompDenied = map[string][]uint32{
+	// socket, ptrace, process_vm_writev, mount, umount2, pivot_root, chroot, unshare, setns,
+	// open_by_handle_at, add_key, request_key, keyctl, bpf, perf_event_open, userfaultfd,
+	// init_module, finit_module, delete_module, kexec_load, reboot, swapon, swapoff
+	"amd64": {41, 101, 311, 165, 166, 155, 161, 272, 308, 304, 248, 249, 250, 321, 298, 323, 175, 313, 176, 246, 169, 167, 168},
+	"arm64": {198, 117, 271, 40, 39, 41, 51, 97, 268, 265, 217, 218, 219, 280, 241, 282, 105, 273, 106, 104, 142, 224, 225},
+}
+
+// installSeccomp loads a filter that denies seccompDenied, and any call made through another
+// architecture's syscall table, with EPERM.
+func installSeccomp() error {
+	const (
+		ldAbs    = 0x20 // BPF_LD | BPF_W | BPF_ABS
+		jeq      = 0x15 // BPF_JMP | BPF_JEQ | BPF_K
+		ret      = 0x06 // BPF_RET | BPF_K
+		retAllow = 0x7fff0000
+		retEPERM = 0x00050000 | uint32(syscall.EPERM)
+		// Offsets into struct seccomp_data.
+		nrOffset   = 0
+		archOffset = 4
+	)
+	denied := seccompDenied[runtime.GOARCH]
+	n := uint8(len(denied))
+	filter := []syscall.SockFilter{
+		{Code: ldAbs, K: archOffset},
+		{Code: jeq, Jt: 1, K: seccompArch[runtime.GOARCH]},
+		{Code: ret, K: retEPERM},
+		{Code: ldAbs, K: nrOffset},
+	}
+	for i, nr := range denied {
+		// Jump over the remaining checks and the allow to the final EPERM.
+		filter = append(filter, syscall.SockFilter{Code: jeq, Jt: n - uint8(i), K: nr})
+	}
+	filter = append(filter, syscall.SockFilter{Code: ret, K: retAllow}, syscall.SockFilter{Code: ret, K: retEPERM})
+	prog := syscall.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	const prSetSeccomp, seccompModeFilter = 22, 2
+	if _, _, e := syscall.RawSyscall(syscall.SYS_PRCTL, prSetSeccomp, seccompModeFilter, uintptr(unsafe.Pointer(&prog))); e != 0 {
+		return e
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(sandboxExecCmd)
+}
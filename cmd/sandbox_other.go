@@ -0,0 +1,10 @@
+//go:build !linux
+
+package cmd
+
+import "os/exec"
+
+// isolate can only give programs a throwaway working directory outside Linux.
+func isolate(c *exec.Cmd, mode sandboxMode) {
+	warnSandbox("the sandbox needs Linux namespaces; programs only get a throwaway working directory.")
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	// asanError is the header of an AddressSanitizer (or Leak/Thread/Memory) report.
+	asanError = regexp.MustCompile(`(?m)^==\d+==ERROR: (\w+Sanitizer): (\S+)`)
+	// asanFrame is a symbolized stack frame such as "#0 0x55d in main A. Sum/main.cpp:12:5". Both
+	// the function and the path may contain spaces, but only the function has brackets or commas.
+	asanFrame = regexp.MustCompile(`(?m)^\s*#\d+ 0x[0-9a-f]+ in (.+?(?: const)?) ([^\s()<>,&][^\n()<>,&]*:\d+(?::\d+)?)$`)
+	// ubsanError is an UndefinedBehaviorSanitizer report, which starts with the location.
+	ubsanError = regexp.MustCompile(`(?m)^(.+?:\d+:\d+): runtime error: (.+)$`)
+	// glibcxxError is the message of a failed _GLIBCXX_DEBUG check, possibly wrapped over lines.
+	glibcxxError = regexp.MustCompile(`(?s)/debug/[^\n]*:\d+:\nIn function:.*?\n\nError: (.+?)\n\n`)
+	// assertFailure is glibc's message for a failed assert, "prog: file:line: func: Assertion `x' failed.".
+	assertFailure = regexp.MustCompile("(?m)^.*: Assertion `(.+)' failed\\.$")
+	// goRaceFrame is the first stack line of a Go race report.
+	goRaceFrame = regexp.MustCompile(`(?m)^\s+(\S+\.go:\d+)`)
+)
+
+// sanitizerReport is what a sanitizer found, parsed out of a program's stderr.
+type sanitizerReport struct {
+	// Tool is e.g. "AddressSanitizer", Kind what it detected, Location the first frame in user code.
+	Tool     string
+	Kind     string
+	Location string
+}
+
+func (r sanitizerReport) String() string {
+	s := r.Tool + ": " + r.Kind
+	if r.Location != "" {
+		s += " at " + r.Location
+	}
+	return s
+}
+
+// parseSanitizerReport finds the first sanitizer, debug-mode STL or race detector report in stderr.
+func parseSanitizerReport(stderr string) (sanitizerReport, bool) {
+	if m := asanError.FindStringSubmatchIndex(stderr); m != nil {
+		r := sanitizerReport{Tool: stderr[m[2]:m[3]], Kind: stderr[m[4]:m[5]], Location: userFrame(stderr[m[1]:])}
+		// With handle_abort the sanitizer only adds the stack to an abort; the reason was printed before.
+		if r.Kind == "ABRT" {
+			if cause, ok := abortCause(stderr[:m[0]]); ok {
+				cause.Location = r.Location
+				return cause, true
+			}
+		}
+		return r, true
+	}
+	if m := ubsanError.FindStringSubmatch(stderr); m != nil {
+		return sanitizerReport{Tool: "UndefinedBehaviorSanitizer", Kind: m[2], Location: shortSourcePath(m[1])}, true
+	}
+	if m := glibcxxError.FindStringSubmatch(stderr); m != nil {
+		return sanitizerReport{Tool: "_GLIBCXX_DEBUG", Kind: strings.Join(strings.Fields(m[1]), " ")}, true
+	}
+	if i := strings.Index(stderr, "WARNING: DATA RACE"); i >= 0 {
+		r := sanitizerReport{Tool: "Go race detector", Kind: "data race"}
+		if m := goRaceFrame.FindStringSubmatch(stderr[i:]); m != nil {
+			r.Location = shortSourcePath(m[1])
+		}
+		return r, true
+	}
+	return sanitizerReport{}, false
+}
+
+// abortCause finds the failed _GLIBCXX_DEBUG check or assertion that made a program abort.
+func abortCause(stderr string) (sanitizerReport, bool) {
+	if m := glibcxxError.FindStringSubmatch(stderr); m != nil {
+		return sanitizerReport{Tool: "_GLIBCXX_DEBUG", Kind: strings.Join(strings.Fields(m[1]), " ")}, true
+	}
+	if m := assertFailure.FindStringSubmatch(stderr); m != nil {
+		return sanitizerReport{Tool: "assert", Kind: m[1] + " failed"}, true
+	}
+	return sanitizerReport{}, false
+}
+
+// userFrame returns the first frame of a sanitizer stack trace that is in the solution's source,
+// as "file:line (in function)", or "" if there is none.
+func userFrame(trace string) string {
+	for _, f := range asanFrame.FindAllStringSubmatch(trace, -1) {
+		if isUserSource(f[2]) {
+			return shortSourcePath(f[2]) + " (in " + f[1] + ")"
+		}
+	}
+	return ""
+}
+
+// isUserSource tells frames in the solution apart from the sanitizer runtime and system libraries.
+func isUserSource(location string) bool {
+	return !strings.HasPrefix(location, "/usr/") && !strings.HasPrefix(location, "../") &&
+		!strings.Contains(location, "libsanitizer") && !strings.Contains(location, "compiler-rt")
+}
+
+// shortSourcePath makes an absolute source location relative to the workspace when it is inside it.
+func shortSourcePath(location string) string {
+	if !filepath.IsAbs(location) {
+		return location
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return location
+	}
+	if rel, err := filepath.Rel(wd, location); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return location
+}
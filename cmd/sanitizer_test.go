@@ -0,0 +1,74 @@
+package cmd
+
+import "testing"
+
+// abortTrace is what AddressSanitizer prints with handle_abort=1 after an abort in get, called
+// from main.
+const abortTrace = `AddressSanitizer:DEADLYSIGNAL
+=================================================================
+==31157==ERROR: AddressSanitizer: ABRT on unknown address 0x0000000079b5 (pc 0x7ffb272a8eec bp 0x7ffb2872c7c0 sp 0x7fffca08f4a0 T0)
+    #0 0x7ffb272a8eec  (/lib/x86_64-linux-gnu/libc.so.6+0x8aeec)
+    #1 0x7ffb27259fb1 in raise (/lib/x86_64-linux-gnu/libc.so.6+0x3bfb1)
+    #2 0x7ffb27244471 in abort (/lib/x86_64-linux-gnu/libc.so.6+0x26471)
+    #3 0x7ffb27c9ffa4  (/lib/x86_64-linux-gnu/libstdc++.so.6+0x9ffa4)
+    #4 0x561dbe6199b6 in std::__debug::vector<int, std::allocator<int> >::operator[](unsigned long) /usr/include/c++/12/debug/vector:442
+    #5 0x561dbe618364 in get(std::__debug::vector<int, std::allocator<int> >&, int) A. Sum/main.cpp:2
+    #6 0x561dbe6184aa in main A. Sum/main.cpp:3
+    #7 0x7ffb27245304 in __libc_start_main (/lib/x86_64-linux-gnu/libc.so.6+0x27304)
+
+AddressSanitizer can not provide additional info.
+SUMMARY: AddressSanitizer: ABRT (/lib/x86_64-linux-gnu/libc.so.6+0x8aeec)
+==31157==ABORTING
+`
+
+const userLocation = "A. Sum/main.cpp:2 (in get(std::__debug::vector<int, std::allocator<int> >&, int))"
+
+func TestParseSanitizerReportAborts(t *testing.T) {
+	for _, tc := range []struct {
+		name, stderr string
+		want         sanitizerReport
+	}{
+		{
+			name: "glibcxx",
+			stderr: `/usr/include/c++/12/debug/vector:442:
+In function:
+    std::debug::vector<_Tp, _Allocator>::reference std::debug::vector<_Tp, 
+    _Allocator>::operator[](size_type) [with _Tp = int]
+
+Error: attempt to subscript container with out-of-bounds index 5, but 
+container only holds 3 elements.
+
+Objects involved in the operation:
+    sequence "this" @ 0x7fffca08f730 {
+    }
+` + abortTrace,
+			want: sanitizerReport{
+				Tool:     "_GLIBCXX_DEBUG",
+				Kind:     "attempt to subscript container with out-of-bounds index 5, but container only holds 3 elements.",
+				Location: userLocation,
+			},
+		},
+		{
+			name:   "assert",
+			stderr: "main: A. Sum/main.cpp:2: int get(std::vector<int>&, int): Assertion `i < 3' failed.\n" + abortTrace,
+			want:   sanitizerReport{Tool: "assert", Kind: "i < 3 failed", Location: userLocation},
+		},
+		{
+			name:   "abort",
+			stderr: abortTrace,
+			want:   sanitizerReport{Tool: "AddressSanitizer", Kind: "ABRT", Location: userLocation},
+		},
+	} {
+		got, ok := parseSanitizerReport(tc.stderr)
+		if !ok || got != tc.want {
+			t.Errorf("%s: parseSanitizerReport = %+v, %v, want %+v", tc.name, got, ok, tc.want)
+		}
+	}
+}
+
+func TestParseSanitizerReportIgnoresPlainAssert(t *testing.T) {
+	// Without a sanitizer a failed assertion is an ordinary runtime error.
+	if r, ok := parseSanitizerReport("main: main.cpp:2: int main(): Assertion `x' failed.\n"); ok {
+		t.Errorf("parseSanitizerReport = %+v, want no report", r)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"strings"
+
+	"github.com/MihaiZegheru/cfr/internal"
 )
 
 import "github.com/spf13/cobra"
@@ -16,35 +18,14 @@ var setLangCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		problemID := args[0]
 		lang := strings.ToLower(args[1])
-		supported := map[string]string{
-			"c": ".c",
-			"cpp": ".cpp",
-			"c++": ".cpp",
-			"rust": ".rs",
-			"python": ".py",
-			"py": ".py",
-			"go": ".go",
-			"java": ".java",
-		}
-		ext, ok := supported[lang]
+		// Load config
+		cfg, _ := internal.LoadConfig()
+		language, ok := cfg.Language(lang)
 		if !ok {
-			fmt.Printf("Unsupported language: %s\n", lang)
+			fmt.Printf("Unsupported language: %s. Available languages: %s\n", lang, strings.Join(cfg.LanguageNames(), ", "))
 			return
 		}
-		// Load config
-		configPath := ".cfr/config.json"
-		type LangConfig struct {
-			DefaultLanguage string            `json:"default_language"`
-			Languages       map[string]string `json:"languages"`
-			Executables     map[string]string `json:"executables"`
-		}
-		var cfg LangConfig
-		cfg.Languages = map[string]string{}
-		if f, err := os.Open(configPath); err == nil {
-			defer f.Close()
-			dec := json.NewDecoder(f)
-			_ = dec.Decode(&cfg)
-		}
+		source := language.SourceFileName()
 		if cfg.Languages == nil {
 			cfg.Languages = map[string]string{}
 		}
@@ -55,19 +36,10 @@ var setLangCmd = &cobra.Command{
 		   }
 		   cfg.Languages[problemID] = lang
 		   // Save config
-		   f, err := os.Create(configPath)
-		   if err != nil {
+		   if err := internal.SaveConfig(cfg); err != nil {
 			   fmt.Printf("Failed to update config: %v\n", err)
 			   return
 		   }
-		   enc := json.NewEncoder(f)
-		   enc.SetIndent("", "  ")
-		   if err := enc.Encode(cfg); err != nil {
-			   fmt.Printf("Failed to write config: %v\n", err)
-			   f.Close()
-			   return
-		   }
-		   f.Close()
 		   fmt.Printf("[CFR] Language for problem %s set to '%s'.\n", problemID, lang)
 		// Create source file if needed
 		// Find problem directory
@@ -83,16 +55,15 @@ var setLangCmd = &cobra.Command{
 			_ = json.Unmarshal(data, &state)
 			if entry, ok := state.Problems[problemID]; ok {
 				dirName := fmt.Sprintf("%s. %s", problemID, entry.Name)
-				// Move non-empty old main.<ext> file to versions/, remove empty ones, except for the new one
-				supportedExts := []string{".c", ".cpp", ".rs", ".py", ".go", ".java"}
+				// Move non-empty old source files to versions/, remove empty ones, except for the new one
 				versionsDir := dirName + string(os.PathSeparator) + "versions"
 				if err := os.MkdirAll(versionsDir, 0755); err != nil {
 					fmt.Printf("[CFR] Warning: Could not create versions directory: %v\n", err)
 				}
-				for _, oldExt := range supportedExts {
-					oldPath := dirName + string(os.PathSeparator) + "main" + oldExt
-					versionPath := versionsDir + string(os.PathSeparator) + "main" + oldExt
-					if oldExt != ext {
+				for _, oldSource := range cfg.SourceFileNames() {
+					oldPath := dirName + string(os.PathSeparator) + oldSource
+					versionPath := versionsDir + string(os.PathSeparator) + oldSource
+					if oldSource != source {
 						if fi, err := os.Stat(oldPath); err == nil {
 							if fi.Size() == 0 {
 								if err := os.Remove(oldPath); err == nil {
@@ -111,8 +82,8 @@ var setLangCmd = &cobra.Command{
 						}
 					}
 				}
-				srcPath := dirName + string(os.PathSeparator) + "main" + ext
-				versionRestore := versionsDir + string(os.PathSeparator) + "main" + ext
+				srcPath := dirName + string(os.PathSeparator) + source
+				versionRestore := versionsDir + string(os.PathSeparator) + source
 				if _, err := os.Stat(srcPath); os.IsNotExist(err) {
 					// Try to restore from versions/
 					if _, err := os.Stat(versionRestore); err == nil {
@@ -0,0 +1,251 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/MihaiZegheru/cfr/internal"
+	"github.com/spf13/cobra"
+)
+
+var shrinkRef string
+var shrinkOutput string
+var shrinkMaxRuns int
+var shrinkBudget time.Duration
+var shrinkChecker string
+
+var shrinkCmd = &cobra.Command{
+	Use:   "shrink <problem_ID> <input_file>",
+	Short: "Minimize a failing test input while keeping it failing",
+	Long: `Minimize a failing test input while keeping it failing.
+
+		The input is reduced step by step: chunks of lines are dropped (lowering a leading test
+		count to match), tokens are dropped (lowering an "n" on the previous line to match) and
+		other numbers are shrunk towards zero. A change is kept only if the solution still fails with
+		the same verdict as on the original input.
+
+		What counts as failing is chosen with --ref:
+			brute    the output differs from brute.<ext> (default if brute.<ext> exists)
+			checker  checker.<ext> rejects the output (default if checker.<ext> exists); it is
+			         given brute.<ext>'s answer if there is one and an empty answer file otherwise
+			crash    the solution crashes, times out or runs out of memory
+
+		Only a wrong answer, a presentation error or a crash counts as failing. An input the
+		checker itself fails on is not shrunk, since that says nothing about the solution.
+
+		The smallest failing input is written after every improvement, by default next to the
+		input as <name>.min<ext>.
+		`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		runShrink(args[0], args[1])
+	},
+}
+
+// runShrink minimizes inputFile for problemID and writes the result to the output file.
+func runShrink(problemID, inputFile string) {
+	pc, err := loadProblemContext(problemID)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if _, err := os.Stat(inputFile); err != nil {
+		// Also accept a file name relative to the problem folder.
+		inputFile = filepath.Join(pc.Dir, inputFile)
+	}
+	data, err := os.ReadFile(inputFile)
+	if err != nil {
+		fmt.Printf("Failed to read input: %v\n", err)
+		return
+	}
+	prog, err := pc.buildSolution()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	classify, err := shrinkClassifier(pc, prog)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	original, msg := classify(string(data))
+	switch original {
+	case verdictOK:
+		fmt.Println("The solution does not fail on this input, nothing to shrink.")
+		return
+	case verdictFail:
+		fmt.Printf("The checker failed on this input, nothing to shrink: %s\n", msg)
+		return
+	}
+	outPath := shrinkOutput
+	if outPath == "" {
+		ext := filepath.Ext(inputFile)
+		outPath = strings.TrimSuffix(inputFile, ext) + ".min" + ext
+	}
+	fmt.Printf("Original input fails with %s (%d bytes). Shrinking...\n", original, len(data))
+
+	start := time.Now()
+	runs := 0
+	best := string(data)
+	s := internal.Shrinker{
+		StillFails: func(input string) bool {
+			runs++
+			v, _ := classify(input)
+			return v == original
+		},
+		OnProgress: func(input string) {
+			best = input
+			os.WriteFile(outPath, []byte(input), 0644)
+			fmt.Printf("\r%d bytes, %d line(s) after %d run(s)...   ", len(input), strings.Count(input, "\n"), runs)
+		},
+		Stop: func() bool {
+			return (shrinkMaxRuns > 0 && runs >= shrinkMaxRuns) || (shrinkBudget > 0 && time.Since(start) >= shrinkBudget)
+		},
+	}
+	s.Shrink(best)
+	os.WriteFile(outPath, []byte(best), 0644)
+	fmt.Printf("\nShrunk %d bytes to %d in %d run(s) (%.1fs). Smallest failing input written to %s\n", len(data), len(best), runs, time.Since(start).Seconds(), outPath)
+}
+
+// shrinkClassifier returns a function that runs the solution on an input and gives the verdict
+// the shrink reference assigns to it, verdictOK meaning "not failing" and verdictFail meaning the
+// checker could not judge the output.
+func shrinkClassifier(pc *problemContext, prog program) (func(string) (verdict, string), error) {
+	ref := shrinkRef
+	if ref == "" || ref == "auto" {
+		switch {
+		case findHelperSource(pc.Config, pc.Dir, "brute") != "":
+			ref = "brute"
+		case findHelperSource(pc.Config, pc.Dir, "checker") != "":
+			ref = "checker"
+		default:
+			ref = "crash"
+		}
+	}
+	solve := func(input string) (runResult, bool) {
+		res, err := runOnInput(prog, pc.Limits, input)
+		return res, err == nil
+	}
+	switch ref {
+	case "crash":
+		return func(input string) (verdict, string) {
+			res, ok := solve(input)
+			if !ok || res.Verdict == "" {
+				return verdictOK, ""
+			}
+			return res.Verdict, ""
+		}, nil
+	case "checker":
+		source := findHelperSource(pc.Config, pc.Dir, "checker")
+		if source == "" {
+			return nil, fmt.Errorf("No checker.<ext> found in %s.", pc.Dir)
+		}
+		checker, err := buildHelper(pc.Config, source, "checker")
+		if err != nil {
+			return nil, err
+		}
+		judge := checkerProgramJudge(checker)
+		// A checker that reads the jury's answer needs a brute force to produce one.
+		answer := func(string) (string, bool) { return "", true }
+		noAnswer := " (it was given an empty answer file; add brute.<ext> if it needs the jury's answer)"
+		if findHelperSource(pc.Config, pc.Dir, "brute") != "" {
+			if answer, err = shrinkAnswerer(pc); err != nil {
+				return nil, err
+			}
+			noAnswer = ""
+		}
+		return func(input string) (verdict, string) {
+			ans, valid := answer(input)
+			if !valid {
+				return verdictOK, ""
+			}
+			res, ok := solve(input)
+			if !ok {
+				return verdictOK, ""
+			}
+			if res.Verdict != "" {
+				return res.Verdict, ""
+			}
+			v, msg := judgedFailure(judge(input, ans, res.Stdout))
+			if v == verdictFail {
+				msg = strings.TrimSpace(msg + noAnswer)
+			}
+			return v, msg
+		}, nil
+	case "brute":
+		answer, err := shrinkAnswerer(pc)
+		if err != nil {
+			return nil, err
+		}
+		spec := shrinkChecker
+		if spec == "" {
+			spec = pc.Config.Problem(pc.ID).Checker
+		}
+		judge, err := resolveJudge(pc.Config, spec, pc.Dir)
+		if err != nil {
+			return nil, err
+		}
+		return func(input string) (verdict, string) {
+			ans, valid := answer(input)
+			if !valid {
+				return verdictOK, ""
+			}
+			res, ok := solve(input)
+			if !ok {
+				return verdictOK, ""
+			}
+			if res.Verdict != "" {
+				return res.Verdict, ""
+			}
+			return judgedFailure(judge(input, ans, res.Stdout))
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown reference %q: use brute, checker or crash", ref)
+}
+
+// shrinkAnswerer builds brute.<ext> and returns a function giving its answer for an input, or
+// false if the brute force rejects the input, which is then probably not a valid test.
+func shrinkAnswerer(pc *problemContext) (func(string) (string, bool), error) {
+	source := findHelperSource(pc.Config, pc.Dir, "brute")
+	if source == "" {
+		return nil, fmt.Errorf("No brute.<ext> found in %s.", pc.Dir)
+	}
+	brute, err := buildHelper(pc.Config, source, "brute")
+	if err != nil {
+		return nil, err
+	}
+	return func(input string) (string, bool) {
+		ans, err := runOnInput(brute, pc.helperRunLimits(), input)
+		if err != nil || ans.Verdict != "" {
+			return "", false
+		}
+		return ans.Stdout, true
+	}, nil
+}
+
+// judgedFailure keeps the verdicts of a judge that say the solution's output is wrong. Anything
+// else the judge reports means it could not judge the output, and becomes verdictFail.
+func judgedFailure(v verdict, msg string) (verdict, string) {
+	switch v {
+	case verdictOK, verdictWA, verdictPE:
+		return v, msg
+	}
+	return verdictFail, msg
+}
+
+func init() {
+	shrinkCmd.Flags().StringVar(&shrinkRef, "ref", "auto", "What decides failure: brute, checker or crash")
+	shrinkCmd.Flags().StringVarP(&shrinkOutput, "output", "o", "", "Where to write the smallest failing input (default <input>.min<ext>)")
+	shrinkCmd.Flags().IntVarP(&shrinkMaxRuns, "max-runs", "n", 2000, "Stop after this many solution runs (0 for no limit)")
+	shrinkCmd.Flags().DurationVarP(&shrinkBudget, "time", "t", 0, "Stop after this much time, e.g. 30s or 5m (default: no limit)")
+	shrinkCmd.Flags().StringVar(&shrinkChecker, "checker", "", "Output comparator used with --ref brute (lines, exact, tokens, nocase, unordered, float[:EPS]) or checker source file")
+	shrinkCmd.Flags().BoolVar(&forceRebuild, "rebuild", false, "Compile from scratch instead of using the build cache")
+	shrinkCmd.Flags().BoolVar(&debugBuild, "debug", false, "Build the solution with sanitizers and debug checks, same as --profile debug")
+	addSandboxFlag(shrinkCmd)
+	shrinkCmd.Flags().StringVar(&profileName, "profile", "", "Build profile for the solution: release, debug or one from config.json")
+	rootCmd.AddCommand(shrinkCmd)
+}
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var stressIterations int
+var stressBudget time.Duration
+var stressSeed int64
+var stressChecker string
+
+// helperLimits bound generators and brute-force solutions, which are trusted but may be slow.
+var helperLimits = runLimits{Time: checkerTimeLimit}
+
+// helperRunLimits are helperLimits sandboxed like the problem's solution.
+func (pc *problemContext) helperRunLimits() runLimits {
+	lim := helperLimits
+	lim.Sandbox = pc.Limits.Sandbox
+	return lim
+}
+
+var stressCmd = &cobra.Command{
+	Use:   "stress <problem_ID>",
+	Short: "Stress test a solution against a brute force on generated inputs",
+	Long: `Stress test a solution against a brute force on generated inputs.
+
+		Put gen.<ext> and brute.<ext> next to main.<ext> in the problem folder, in any supported
+		language. Each iteration runs 'gen <seed>' and feeds its output to both the solution and the
+		brute force, then compares the two outputs (with --checker, a checker file, or line by line).
+
+		The loop stops at the first mismatch, timeout or crash. The counterexample is saved in the
+		problem folder as stress_in.txt, with the solution's output in stress_out.txt and the brute
+		force's in stress_ans.txt.
+		`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runStress(args[0])
+	},
+}
+
+// runStress compiles the solution, generator and brute force for problemID and compares them
+// on generated tests until one fails or the iteration or time budget runs out.
+func runStress(problemID string) {
+	pc, err := loadProblemContext(problemID)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	var helpers [2]program
+	for i, name := range []string{"gen", "brute"} {
+		source := findHelperSource(pc.Config, pc.Dir, name)
+		if source == "" {
+			fmt.Printf("No %s.<ext> found in %s.\n", name, pc.Dir)
+			return
+		}
+		if helpers[i], err = buildHelper(pc.Config, source, name); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	gen, brute := helpers[0], helpers[1]
+	prog, err := pc.buildSolution()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	spec := stressChecker
+	if spec == "" {
+		spec = pc.Config.Problem(problemID).Checker
+	}
+	judge, err := resolveJudge(pc.Config, spec, pc.Dir)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	start := time.Now()
+	for i := 0; stressIterations <= 0 || i < stressIterations; i++ {
+		if stressBudget > 0 && time.Since(start) >= stressBudget {
+			fmt.Printf("\nTime budget of %s used up after %d iteration(s). No counterexample found.\n", stressBudget, i)
+			return
+		}
+		seed := stressSeed + int64(i)
+		fmt.Printf("\rIteration %d (seed %d)...", i+1, seed)
+		genArgs := append(append([]string{}, gen.Args...), strconv.FormatInt(seed, 10))
+		genRes, err := runOnInput(program{Cmd: gen.Cmd, Args: genArgs, Dir: gen.Dir, Env: gen.Env}, pc.helperRunLimits(), "")
+		if err != nil || genRes.Verdict != "" {
+			fmt.Printf("\nGenerator failed on seed %d: %s\n", seed, describeHelperFailure(genRes, err))
+			printDebugOutput(genRes.Stderr, "")
+			return
+		}
+		input := genRes.Stdout
+		ansRes, err := runOnInput(brute, pc.helperRunLimits(), input)
+		if err != nil || ansRes.Verdict != "" {
+			fmt.Printf("\nBrute force failed on seed %d: %s\n", seed, describeHelperFailure(ansRes, err))
+			saveCounterexample(pc.Dir, input, "", ansRes.Stdout)
+			return
+		}
+		res, err := runOnInput(prog, pc.Limits, input)
+		if err != nil {
+			fmt.Printf("\nExecution failed: %v\n", err)
+			return
+		}
+		v, msg := res.Verdict, ""
+		if v == "" {
+			v, msg = judge(input, ansRes.Stdout, res.Stdout)
+		}
+		if v == verdictOK {
+			continue
+		}
+		if res.Verdict != "" {
+			msg = describeRunFailure(res, pc.Limits)
+		} else {
+			msg = withMessage(v, msg)
+		}
+		fmt.Printf("\nCounterexample found on seed %d: %s\n", seed, msg)
+		printDebugOutput(res.Stderr, "")
+		saveCounterexample(pc.Dir, input, res.Stdout, ansRes.Stdout)
+		return
+	}
+	fmt.Printf("\nNo counterexample found in %d iteration(s) (%.1fs).\n", stressIterations, time.Since(start).Seconds())
+}
+
+// describeHelperFailure explains why a generator or brute force run failed.
+func describeHelperFailure(res runResult, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return describeRunFailure(res, helperLimits)
+}
+
+// saveCounterexample writes a failing stress test into the problem folder.
+func saveCounterexample(dir, input, output, answer string) {
+	files := []struct{ name, content string }{
+		{"stress_in.txt", input},
+		{"stress_out.txt", output},
+		{"stress_ans.txt", answer},
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f.name)
+		if err := os.WriteFile(path, []byte(f.content), 0644); err != nil {
+			fmt.Printf("Failed to write %s: %v\n", path, err)
+			return
+		}
+	}
+	fmt.Printf("Saved input to %s, outputs to stress_out.txt (solution) and stress_ans.txt (brute force).\n", filepath.Join(dir, "stress_in.txt"))
+}
+
+func init() {
+	stressCmd.Flags().IntVarP(&stressIterations, "iterations", "n", 1000, "Maximum number of iterations (0 for no limit)")
+	stressCmd.Flags().DurationVarP(&stressBudget, "time", "t", 0, "Stop after this much time, e.g. 30s or 5m (default: no limit)")
+	stressCmd.Flags().Int64Var(&stressSeed, "seed", 1, "Seed passed to the generator on the first iteration")
+	stressCmd.Flags().StringVar(&stressChecker, "checker", "", "Output comparator (lines, exact, tokens, nocase, unordered, float[:EPS]) or checker source file")
+	stressCmd.Flags().BoolVar(&forceRebuild, "rebuild", false, "Compile from scratch instead of using the build cache")
+	stressCmd.Flags().BoolVar(&debugBuild, "debug", false, "Build the solution with sanitizers and debug checks, same as --profile debug")
+	addSandboxFlag(stressCmd)
+	stressCmd.Flags().StringVar(&profileName, "profile", "", "Build profile for the solution: release, debug or one from config.json")
+	rootCmd.AddCommand(stressCmd)
+}
@@ -1,25 +1,65 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
-	"encoding/json"
-	"github.com/spf13/cobra"
+
 	"github.com/MihaiZegheru/cfr/internal"
+	"github.com/spf13/cobra"
 )
 
 var customTest bool
+var checkerName string
+var testJobsFlag int
+var serialTests bool
+var testFormat string
+var testAll bool
+var testWatch bool
 
 var testCmd = &cobra.Command{
-	 Use:   "test <problem_ID>",
-	 Short: "Test a problem by ID",
-			 Long: `Test a problem by ID.
+	Use:   "test <problem_ID>... | --all",
+	Short: "Test one or more problems by ID",
+	Long: `Test one or more problems by ID.
 
-		By default, runs all sample tests for the problem.
+		By default, runs all tests in the problem's tests folder (01.in with answer 01.ans, ...): the
+		samples from the statement, then the custom tests added with 'cfr tests add'. With several IDs, or --all for every
+		loaded problem, each problem is compiled and tested in turn and a table with passed tests,
+		the worst verdict and the slowest test per problem is printed at the end. A problem that
+		fails to compile does not stop the others.
 
 		Use -c to run a custom test: input is read from in.txt and output is written to out.txt in the problem directory.
 
+		Compiled binaries are cached in .cfr/build-cache, keyed by the source, its local headers, the
+		compiler version and the flags, so unchanged solutions are not recompiled. Use --rebuild to
+		compile anyway.
+
+		Use --debug (or "debug": true in config.json) to build with sanitizers: C/C++ get
+		-fsanitize=address,undefined -D_GLIBCXX_DEBUG -DLOCAL and Go gets -race. Sanitizer reports are
+		shown as Sanitizer Error with the source location.
+
+		Build profiles:
+			- --profile NAME picks how the solution is built; release and debug are built in.
+			- Profiles in config.json set compiler, flags, defines, include_paths and env per language,
+			  and a problem can pick one with "profile":
+				{
+					"profiles": { "cf-gnu20": { "cpp": { "flags": ["-O2", "-std=c++20"] } } },
+					"problems": { "A": { "profile": "cf-gnu20" } }
+				}
+
+		Selecting tests:
+			- -t picks tests by number: -t 2, -t 2-4 or -t 1,3,5-7.
+			- --only sample or --only custom runs one kind of test.
+			- --failed reruns only the tests that failed the last time they ran. The last result of
+			  every test is kept in .cfr/results.json.
+			- --fail-fast stops at the first failed test.
+
+		Tests run in parallel, one per CPU by default; use -j N to change that or --serial for
+		timing-sensitive solutions. Results are always reported in test order.
+
 		Language selection:
 			- The language for each problem can be set in .cfr/config.json:
 				{
@@ -29,210 +69,314 @@ var testCmd = &cobra.Command{
 						"B": "python"
 					}
 				}
-			- Supported languages: cpp, c, rust, go, python, java
+			- Built-in languages: cpp, c, go, python, java, rust. Others can be defined under "custom_languages"
+			  with a source extension and compile/run command templates (see the README).
 			- If a problem is not listed in 'languages', 'default_language' is used.
+
+		Java:
+			- main.java holds 'public class Main'; it is compiled with javac into the problem's build/ folder.
+			- JVM flags such as -Xss and -Xmx are set as "run_flags" of "java" in a build profile.
+			- JVM startup time is reported once and not counted in test times or the time limit.
+
+		Rust:
+			- main.rs is compiled with rustc in release mode, with the edition and flags Codeforces uses.
+			- With a Cargo.toml in the workspace root, solutions are built offline through that cargo
+			  project instead, so its vendored crates can be used. The profile's flags are passed to
+			  rustc, apart from the edition and optimization level, which cargo decides.
+
+		Time and memory limits:
+			- Every run is killed once the problem's time limit expires and reported as Time Limit Exceeded.
+			- Memory is capped at the problem's memory limit; allocation failures are reported as Memory Limit Exceeded.
+			- Output is capped at 64 MB ("output_limit_mb" in config.json); a solution writing more is
+			  killed and reported as Output Limit Exceeded.
+			- Limits are scraped from the statement by 'cfr load' and can be overridden per problem:
+				{
+					"problems": {
+						"A": { "time_limit_ms": 3000, "memory_limit_mb": 512 }
+					}
+				}
+
+		Sandbox:
+			- --sandbox (or "sandbox": "on" in config.json) runs solutions and generators in a throwaway
+			  copy of the problem folder, so files they write are discarded after each run.
+			- On Linux they also get private user, mount and network namespaces: no network, and the
+			  rest of the filesystem is read-only. --sandbox=seccomp additionally denies system calls
+			  such as socket, ptrace and mount.
+			- Where namespaces are not available, cfr warns and uses only the throwaway folder.
+
+		Checkers:
+			- Output is compared line by line by default. Pick another comparison per problem with
+			  "checker" in the "problems" section of .cfr/config.json, or with --checker:
+				lines, exact, tokens, nocase, unordered, float[:EPS] (e.g. float:1e-9)
+			- A checker.<ext> file in the problem folder (or a file named by "checker") is compiled
+			  and run testlib-style as 'checker <input> <output> <answer>'; exit code 0 means OK,
+			  1 Wrong Answer, 2 Presentation Error, 3 checker failure.
+
+		Interactive problems:
+			- If the problem folder has an interactor.<ext> file (or config.json names one with
+			  "interactor"), the solution talks to it over stdin/stdout instead of reading a file.
+			- The interactor is run as 'interactor <input> <output>' with the test input and reports the
+			  verdict through testlib exit codes. A transcript is shown for failed tests; with -c it is
+			  written to out.txt.
+
+		Watch mode:
+			- With --watch, cfr keeps running and reruns the tests (or -c) whenever main.<ext>, a local
+			  header it includes with #include "...", the tests, config.json or the checker/interactor
+			  change. A run still in progress is killed when a new change arrives. Stop with Ctrl-C.
+
+		Reports:
+			- Every verdict shows user and system CPU time, wall time and peak memory, with a warning
+			  when any of them goes above 80% of the problem's limit.
+			- --format json prints one record per test (problem, test, verdict, message, times, memory,
+			  warnings, output, expected answer) followed by a summary; --format junit prints JUnit XML for CI.
+			  Long outputs are truncated. Progress messages go to stderr so stdout stays parseable.
 		`,
-	 Args:  cobra.ExactArgs(1),
-			Run: func(cmd *cobra.Command, args []string) {
-		   state, err := internal.LoadProblemsState()
-		   if err != nil || state.ContestID == "" {
-			   fmt.Println("No contest ID loaded. Please run 'cfr load <ID>' first.")
-			   return
-		   }
-		   problemID := args[0]
-		   prob, ok := state.Problems[problemID]
-		   if !ok {
-			   fmt.Printf("Problem %s not found in state. Please run 'cfr load <ID>' again.\n", problemID)
-			   return
-		   }
-		  // Detect language from config
-		  lang := "cpp"
-		  configPath := ".cfr/config.json"
-		  type LangConfig struct {
-			  DefaultLanguage string            `json:"default_language"`
-			  Languages       map[string]string `json:"languages"`
-			  Executables     map[string]string `json:"executables"`
-		  }
-		  var cfg LangConfig
-		  if f, err := os.Open(configPath); err == nil {
-			  defer f.Close()
-			  dec := json.NewDecoder(f)
-			  if err := dec.Decode(&cfg); err == nil {
-				  if l, ok := cfg.Languages[problemID]; ok {
-					  lang = strings.ToLower(l)
-				  } else if cfg.DefaultLanguage != "" {
-					  lang = strings.ToLower(cfg.DefaultLanguage)
-				  }
-			  }
-		  }
-		  ext := map[string]string{
-			  "c": ".c",
-			  "cpp": ".cpp",
-			  "c++": ".cpp",
-			  "rust": ".rs",
-			  "python": ".py",
-			  "py": ".py",
-			  "go": ".go",
-			  "java": ".java",
-		  }[lang]
-		  if ext == "" {
-			  fmt.Println("No valid language set in .cfr/config.json. Cannot test.")
-			  return
-		  }
-		   // Find the problem directory (should match the format <problemID>. <name>)
-		   probDir := ""
-		   for id, entry := range state.Problems {
-			   if id == problemID {
-				   probDir = fmt.Sprintf("%s. %s", id, entry.Name)
-				   break
-			   }
-		   }
-		   if probDir == "" {
-			   fmt.Printf("Directory for problem %s not found.\n", problemID)
-			   return
-		   }
-		   sourceFile := probDir + string(os.PathSeparator) + "main" + ext
-		   // Compile the solution
-		   var execCmd string
-		   var execArgs []string
-		   var binName string
-		   var binPath string
-		  // Select executable from config or use default
-		  defaultExecs := map[string]string{
-			  "cpp": "g++",
-			  "c": "gcc",
-			  "go": "go",
-			  "python": "python",
-			  "py": "python",
-		  }
-		  getExec := func(lang string) string {
-			  if cfg.Executables != nil {
-				  if exe, ok := cfg.Executables[lang]; ok && exe != "" {
-					  return exe
-				  }
-			  }
-			  if exe, ok := defaultExecs[lang]; ok {
-				  return exe
-			  }
-			  return ""
-		  }
-		  switch lang {
-		  case "cpp", "c++":
-			  binName = problemID + ".exe"
-			  binPath = probDir + string(os.PathSeparator) + binName
-			  execCmd = getExec("cpp")
-			  execArgs = []string{"-O2", "-std=c++17", sourceFile, "-o", binPath}
-		  case "c":
-			  binName = problemID + ".exe"
-			  binPath = probDir + string(os.PathSeparator) + binName
-			  execCmd = getExec("c")
-			  execArgs = []string{"-O2", sourceFile, "-o", binPath}
-		  case "go":
-			  binName = problemID + ".exe"
-			  binPath = probDir + string(os.PathSeparator) + binName
-			  execCmd = getExec("go")
-			  execArgs = []string{"build", "-o", binPath, sourceFile}
-		  case "python", "py":
-			  binName = ""
-			  binPath = ""
-		  default:
-			  fmt.Println("Language not supported for testing.")
-			  return
-		  }
-		   if lang != "python" && lang != "py" {
-			   fmt.Printf("Compiling %s...\n", sourceFile)
-			   out, err := runAndCapture(execCmd, execArgs...)
-			   if err != nil {
-				   fmt.Printf("Compilation failed: %v\n%s\n", err, out)
-				   return
-			   }
-			   fmt.Println("Compilation successful.")
-		   }
-		   // Prepare run command
-		  var runCmd string
-		  var runArgs []string
-		  if lang == "python" || lang == "py" {
-			  runCmd = getExec(lang)
-			  runArgs = []string{sourceFile}
-		  } else {
-			  runCmd = "." + string(os.PathSeparator) + binName
-			  runArgs = []string{}
-		  }
-
-		   if customTest {
-			   // Use in.txt as input, write output to out.txt in the problem directory
-			   inPath := probDir + string(os.PathSeparator) + "in.txt"
-			   outPath := probDir + string(os.PathSeparator) + "out.txt"
-			   if _, err := os.Stat(inPath); os.IsNotExist(err) {
-				   fmt.Printf("%s not found. Please run 'cfr load <ID>' first.\n", inPath)
-				   return
-			   }
-			   runCwd := ""
-			   if lang != "python" && lang != "py" && lang != "java" {
-				   runCwd = probDir
-			   }
-			   output, err := runWithInputCwd(runCmd, runArgs, inPath, runCwd)
-			   if err != nil {
-				   fmt.Printf("Execution failed: %v\n", err)
-				   return
-			   }
-			   os.WriteFile(outPath, []byte(output), 0644)
-			   fmt.Printf("Custom test complete. Output written to %s\n", outPath)
-			   return
-		   }
-
-		   if len(prob.Tests) == 0 {
-			   fmt.Printf("No sample tests found for problem %s.\n", problemID)
-			   return
-		   }
-		   // Run each test case
-		   fmt.Printf("Running %d sample test(s)...\n", len(prob.Tests))
-		   for i, tc := range prob.Tests {
-			   // Write input to temp file in the problem directory
-			   inFile := probDir + string(os.PathSeparator) + fmt.Sprintf("tmp_input_%d.txt", i)
-			   os.WriteFile(inFile, []byte(tc.Input), 0644)
-			   // If running a compiled language, run from the problem directory
-			   runCwd := ""
-			   if lang != "python" && lang != "py" && lang != "java" {
-				   runCwd = probDir
-			   }
-			   output, err := runWithInputCwd(runCmd, runArgs, inFile, runCwd)
-			   // Clean up input file
-			   os.Remove(inFile)
-			   fmt.Printf("Test #%d:\n", i+1)
-			   if err != nil {
-				   fmt.Printf("  Execution failed: %v\n", err)
-				   continue
-			   }
-			   // Normalize output for comparison: trim trailing spaces per line and ignore extra blank lines at end
-			   normalize := func(s string) string {
-				   lines := strings.Split(s, "\n")
-				   var cleaned []string
-				   for _, line := range lines {
-					   cleaned = append(cleaned, strings.TrimRight(line, " \t\r"))
-				   }
-				   // Remove trailing empty lines
-				   for len(cleaned) > 0 && cleaned[len(cleaned)-1] == "" {
-					   cleaned = cleaned[:len(cleaned)-1]
-				   }
-				   return strings.Join(cleaned, "\n")
-			   }
-			   userOut := normalize(output)
-			   expected := normalize(tc.Output)
-			   if userOut == expected {
-				   fmt.Println("  OK")
-			   } else {
-				   fmt.Println("  Wrong Answer")
-				   fmt.Println("  Your output:")
-				   fmt.Println(userOut)
-				   fmt.Println("  Expected output:")
-				   fmt.Println(expected)
-			   }
-		   }
-       },
+	Args: func(cmd *cobra.Command, args []string) error {
+		if testAll {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ids := args
+		if testAll {
+			var err error
+			if ids, err = loadedProblemIDs(); err != nil {
+				fmt.Println(err)
+				return
+			}
+		}
+		if customTest {
+			if len(ids) > 1 {
+				fmt.Println("-c runs a single problem's custom test; pass one problem ID.")
+				return
+			}
+			testFormat = "text"
+		}
+		if testWatch {
+			if len(ids) != 1 || testFormat != "text" {
+				fmt.Println("--watch works on a single problem with text output.")
+				return
+			}
+			watchTests(ids[0])
+			return
+		}
+		rep, err := newTestReporter(testFormat)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		for i, id := range ids {
+			if len(ids) > 1 {
+				if i > 0 {
+					fmt.Fprintln(statusOut)
+				}
+				fmt.Fprintf(statusOut, "=== Problem %s ===\n", id)
+			}
+			runTests(id, rep)
+		}
+		rep.finish()
+	},
+}
+
+// runTests compiles the solution for problemID and runs it on its tests, or on in.txt with -c.
+// Test results go to rep.
+func runTests(problemID string, rep testReporter) {
+	pc, err := loadProblemContext(problemID)
+	if err != nil {
+		rep.problemFailed(problemID, verdictExecFail, err.Error())
+		return
+	}
+	var selected []int
+	if !customTest {
+		if selected, err = selectTests(problemID, pc.Tests); err != nil {
+			rep.problemFailed(problemID, "", err.Error())
+			return
+		}
+	}
+	prog, err := pc.buildSolution()
+	if err != nil {
+		v := verdictExecFail
+		var ce *compileError
+		if errors.As(err, &ce) {
+			v = verdictCE
+		}
+		rep.problemFailed(problemID, v, err.Error())
+		return
+	}
+	interactor, err := resolveInteractor(pc.Config, problemID, pc.Dir)
+	if err != nil {
+		rep.problemFailed(problemID, verdictFail, err.Error())
+		return
+	}
+
+	if customTest {
+		runCustomTest(pc, prog, interactor)
+		return
+	}
+
+	tests := pc.Tests
+	// The last result of every test that runs is recorded for --failed.
+	ran := map[string]internal.TestResult{}
+	finished := func(rec testRecord) bool {
+		rep.testFinished(rec)
+		ran[rec.Name] = testResult(rec)
+		return !testFailFast || rec.Verdict == verdictOK
+	}
+	defer func() {
+		if testFailFast && len(ran) > 0 && len(ran) < len(selected) {
+			fmt.Fprintln(statusOut, "Stopped at the first failed test (--fail-fast).")
+		}
+		if err := internal.RecordTestResults(problemID, tests, ran); err != nil {
+			fmt.Fprintf(statusOut, "Warning: could not record the test results: %v\n", err)
+		}
+	}()
+	if interactor != nil {
+		rep.problemStarted(problemID, len(selected), true, pc.Limits)
+		results := make([]interaction, len(selected))
+		errs := make([]error, len(selected))
+		runOrdered(len(selected), testJobs(), func(i int) {
+			results[i], errs[i] = runInteraction(prog, pc.Limits, *interactor, tests[selected[i]].Input)
+		}, func(i int) bool {
+			t := tests[selected[i]]
+			rec := interactionRecord(problemID, selected[i], results[i], errs[i], pc.Limits)
+			rec.Name, rec.Kind = t.Name, t.TestKind()
+			return finished(rec)
+		})
+		return
+	}
+	spec := checkerName
+	if spec == "" {
+		spec = pc.Config.Problem(problemID).Checker
+	}
+	judge, err := resolveJudge(pc.Config, spec, pc.Dir)
+	if err != nil {
+		rep.problemFailed(problemID, verdictFail, err.Error())
+		return
+	}
+	// A checker program judges custom tests without an answer too, given an empty answer file.
+	needsAnswer := checkerSource(pc.Config, spec, pc.Dir) == ""
+	rep.problemStarted(problemID, len(selected), false, pc.Limits)
+	outcomes := make([]testOutcome, len(selected))
+	runOrdered(len(selected), testJobs(), func(i int) {
+		outcomes[i] = runTest(prog, pc.Limits, judge, needsAnswer, tests[selected[i]])
+	}, func(i int) bool {
+		return finished(outcomeRecord(problemID, selected[i], tests[selected[i]], outcomes[i], pc.Limits))
+	})
+}
+
+// runCustomTest runs the solution on in.txt and writes its output, or the interaction
+// transcript, to out.txt in the problem directory.
+func runCustomTest(pc *problemContext, prog program, interactor *program) {
+	inPath := filepath.Join(pc.Dir, "in.txt")
+	outPath := filepath.Join(pc.Dir, "out.txt")
+	if _, err := os.Stat(inPath); os.IsNotExist(err) {
+		fmt.Printf("%s not found. Please run 'cfr load <ID>' first.\n", inPath)
+		return
+	}
+	if interactor != nil {
+		input, _ := os.ReadFile(inPath)
+		it, err := runInteraction(prog, pc.Limits, *interactor, string(input))
+		if err != nil {
+			fmt.Printf("Execution failed: %v\n", err)
+			return
+		}
+		os.WriteFile(outPath, []byte(it.Transcript), 0644)
+		printDebugOutput(it.Solution.Stderr, "")
+		fmt.Println(describeInteraction(it, pc.Limits))
+		fmt.Printf("Interaction transcript written to %s\n", outPath)
+		return
+	}
+	input, _ := os.ReadFile(inPath)
+	res, err := runOnInput(prog, pc.Limits, string(input))
+	if err != nil {
+		fmt.Printf("Execution failed: %v\n", err)
+		return
+	}
+	os.WriteFile(outPath, []byte(res.Stdout), 0644)
+	printDebugOutput(res.Stderr, "")
+	usage, warnings := resultUsage(res, pc.Limits)
+	for _, w := range warnings {
+		fmt.Println("Warning: " + w)
+	}
+	if res.Verdict != "" {
+		fmt.Printf("%s (%s)\n", describeRunFailure(res, pc.Limits), usage)
+		return
+	}
+	fmt.Printf("Custom test complete (%s). Output written to %s\n", usage, outPath)
+}
+
+// loadedProblemIDs lists every problem in the workspace state in letter order.
+func loadedProblemIDs() ([]string, error) {
+	state, err := internal.LoadProblemsState()
+	if err != nil {
+		return nil, errors.New("No contest ID loaded. Please run 'cfr load <ID>' first.")
+	}
+	ids := make([]string, 0, len(state.Problems))
+	for id := range state.Problems {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// testOutcome is the result of running and judging a solution on one test.
+type testOutcome struct {
+	Res     runResult
+	Err     error
+	Verdict verdict
+	Message string
+}
+
+// runTest runs prog on a test with its input piped to stdin and judges the output. If the judge
+// needs an answer, a custom test without one is only checked for crashes and limits.
+func runTest(prog program, limits runLimits, judge outputJudge, needsAnswer bool, tc internal.TestCase) testOutcome {
+	var o testOutcome
+	o.Res, o.Err = runOnInput(prog, limits, tc.Input)
+	switch {
+	case o.Err != nil:
+	case o.Res.Verdict != "":
+		o.Verdict = o.Res.Verdict
+	case needsAnswer && tc.TestKind() == internal.TestCustom && tc.Output == "":
+		o.Verdict, o.Message = verdictOK, "no answer to compare with"
+	default:
+		o.Verdict, o.Message = judge(tc.Input, tc.Output, o.Res.Stdout)
+	}
+	return o
+}
+
+// testJobs is how many tests run at once, from -j and --serial.
+func testJobs() int {
+	if serialTests {
+		return 1
+	}
+	return workerCount(testJobsFlag)
+}
+
+// runOnInput runs prog with input piped to its stdin.
+func runOnInput(prog program, limits runLimits, input string) (runResult, error) {
+	p, err := startProgram(runSpec{Cmd: prog.Cmd, Args: prog.Args, Dir: prog.Dir, Env: prog.Env, Limits: limits, Startup: prog.Startup, Stdin: strings.NewReader(input)})
+	if err != nil {
+		return runResult{}, err
+	}
+	return p.wait(), nil
 }
 
 func init() {
 	testCmd.Flags().BoolVarP(&customTest, "custom", "c", false, "Run against custom test")
+	testCmd.Flags().IntVarP(&testJobsFlag, "jobs", "j", 0, "Number of tests to run in parallel (default: number of CPUs)")
+	testCmd.Flags().BoolVar(&serialTests, "serial", false, "Run tests one at a time, for timing-sensitive solutions")
+	testCmd.Flags().StringVar(&checkerName, "checker", "", "Output comparator (lines, exact, tokens, nocase, unordered, float[:EPS]) or checker source file")
+	testCmd.Flags().BoolVar(&testAll, "all", false, "Test every loaded problem")
+	testCmd.Flags().BoolVarP(&testWatch, "watch", "w", false, "Rerun the tests whenever the solution or tests change")
+	testCmd.Flags().StringVar(&testFormat, "format", "text", "Report format: text, json or junit")
+	testCmd.Flags().BoolVar(&forceRebuild, "rebuild", false, "Compile from scratch instead of using the build cache")
+	testCmd.Flags().BoolVar(&debugBuild, "debug", false, "Build the solution with sanitizers and debug checks, same as --profile debug")
+	testCmd.Flags().StringVarP(&testSelection, "tests", "t", "", "Tests to run by number, e.g. 2, 2-4 or 1,3,5-7")
+	testCmd.Flags().StringVar(&testOnly, "only", "", "Run only sample or only custom tests")
+	testCmd.Flags().BoolVar(&testFailed, "failed", false, "Run only the tests that failed last time")
+	testCmd.Flags().BoolVar(&testFailFast, "fail-fast", false, "Stop at the first failed test")
+	addSandboxFlag(testCmd)
+	testCmd.Flags().StringVar(&profileName, "profile", "", "Build profile for the solution: release, debug or one from config.json")
 	rootCmd.AddCommand(testCmd)
 }
@@ -0,0 +1,404 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/MihaiZegheru/cfr/internal"
+	"github.com/spf13/cobra"
+)
+
+// The markers between a test's input and answer in the text 'cfr tests show' prints and
+// 'cfr tests add' and 'cfr tests edit' read.
+const (
+	testInputMarker  = "=== input ==="
+	testAnswerMarker = "=== answer ==="
+)
+
+// testPreviewWidth is how much of a test's first line 'cfr tests list' shows.
+const testPreviewWidth = 32
+
+var testsCmd = &cobra.Command{
+	Use:   "tests",
+	Short: "Add, list, show, edit and remove the tests of a problem",
+	Long: `Add, list, show, edit and remove the tests of a problem.
+
+		Every problem starts with the sample tests from its statement. Tests added with
+		'cfr tests add' are custom tests: 'cfr test' runs them after the samples, and reloading the
+		contest replaces the samples but keeps them. Tests are numbered as 'cfr test' reports them.
+
+		Each test is a pair of files in the problem's tests folder, such as tests/01.in with the
+		input and tests/01.ans with the expected answer. They can be edited with any tool, and
+		files put there by hand are picked up as custom tests.
+
+		A test is written as its input and its expected answer, each after a marker line:
+			=== input ===
+			3
+			1 2 3
+			=== answer ===
+			6
+		Text without markers is all input. A custom test without an answer is judged by the
+		problem's checker program, given an empty answer file; with a built-in comparator it is
+		only checked for crashes and limits.
+		`,
+}
+
+var testsAddCmd = &cobra.Command{
+	Use:   "add <PROBLEM_ID> [INPUT_FILE [ANSWER_FILE]]",
+	Short: "Add a custom test from files, stdin or $EDITOR",
+	Long: `Add a custom test from files, stdin or $EDITOR.
+
+		With files, the test's input and answer are read from them. Otherwise the test is read from
+		stdin if it is not a terminal, e.g. 'cfr tests add A < test.txt', or written in $EDITOR.
+		The test is saved as the next number in the problem's tests folder.
+		`,
+	Args: cobra.RangeArgs(1, 3),
+	Run: func(cmd *cobra.Command, args []string) {
+		pt, err := loadProblemTests(args[0])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		var tc internal.TestCase
+		switch {
+		case len(args) > 1:
+			tc, err = readTestFiles(args[1:])
+		case !isTerminal(os.Stdin):
+			var data []byte
+			if data, err = io.ReadAll(os.Stdin); err == nil {
+				tc = parseTestText(string(data))
+			}
+		default:
+			var text string
+			if text, err = editText(formatTestText(tc)); err == nil {
+				tc = parseTestText(text)
+			}
+		}
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if strings.TrimSpace(tc.Input) == "" {
+			fmt.Println("The test has no input; nothing was added.")
+			return
+		}
+		tc.Name, tc.Kind = internal.NextTestName(pt.dir, pt.entry.Tests), internal.TestCustom
+		if err := internal.SaveTest(pt.dir, tc); err != nil {
+			fmt.Printf("Failed to save the test: %v\n", err)
+			return
+		}
+		if err := pt.saveMeta(append(pt.entry.Tests, internal.TestCase{Name: tc.Name, Kind: tc.Kind})); err != nil {
+			fmt.Println(err)
+			return
+		}
+		in, _ := internal.TestFiles(pt.dir, tc.Name)
+		fmt.Printf("Added test #%d to problem %s as %s.\n", pt.position(tc.Name), args[0], in)
+	},
+}
+
+var testsListCmd = &cobra.Command{
+	Use:   "list <PROBLEM_ID>",
+	Short: "List the tests of a problem",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		pt, err := loadProblemTests(args[0])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if len(pt.tests) == 0 {
+			fmt.Printf("Problem %s has no tests. Add one with 'cfr tests add %s'.\n", args[0], args[0])
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "Test\tFile\tKind\tInput\tAnswer")
+		for i, tc := range pt.tests {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", i+1, tc.Name, tc.TestKind(), previewText(tc.Input), previewText(tc.Output))
+		}
+		w.Flush()
+	},
+}
+
+var testsShowCmd = &cobra.Command{
+	Use:   "show <PROBLEM_ID> <TEST>",
+	Short: "Print a test's input and answer",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		pt, err := loadProblemTests(args[0])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		i, err := testIndex(pt.tests, args[1])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Print(formatTestText(pt.tests[i]))
+	},
+}
+
+var testsEditCmd = &cobra.Command{
+	Use:   "edit <PROBLEM_ID> <TEST>",
+	Short: "Edit a test in $EDITOR",
+	Long: `Edit a test in $EDITOR.
+
+		The test's files in the tests folder can also be edited directly. Edits to a sample test last
+		until the contest is reloaded, which restores the samples.
+		`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		pt, err := loadProblemTests(args[0])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		i, err := testIndex(pt.tests, args[1])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		text, err := editText(formatTestText(pt.tests[i]))
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		edited := parseTestText(text)
+		if strings.TrimSpace(edited.Input) == "" {
+			fmt.Println("The test has no input; it was left unchanged.")
+			return
+		}
+		edited.Name, edited.Kind = pt.tests[i].Name, pt.tests[i].Kind
+		if err := internal.SaveTest(pt.dir, edited); err != nil {
+			fmt.Printf("Failed to save the test: %v\n", err)
+			return
+		}
+		fmt.Printf("Updated test #%s of problem %s.\n", args[1], args[0])
+	},
+}
+
+var testsRmCmd = &cobra.Command{
+	Use:     "rm <PROBLEM_ID> <TEST>...",
+	Aliases: []string{"remove"},
+	Short:   "Remove tests from a problem",
+	Long: `Remove tests from a problem. Their files are deleted and the tests after them are
+		renumbered.
+
+		Removed sample tests come back when the contest is reloaded.
+		`,
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		pt, err := loadProblemTests(args[0])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		remove := map[string]bool{}
+		for _, arg := range args[1:] {
+			i, err := testIndex(pt.tests, arg)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			remove[pt.tests[i].Name] = true
+		}
+		for name := range remove {
+			if err := internal.RemoveTest(pt.dir, name); err != nil {
+				fmt.Printf("Failed to remove test %s: %v\n", name, err)
+				return
+			}
+		}
+		var kept []internal.TestCase
+		for _, t := range pt.entry.Tests {
+			if !remove[t.Name] {
+				kept = append(kept, t)
+			}
+		}
+		if err := pt.saveMeta(kept); err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("Removed %d test(s) from problem %s.\n", len(remove), args[0])
+	},
+}
+
+// problemTests are the tests of one problem, as read from its tests folder.
+type problemTests struct {
+	state internal.ProblemsState
+	id    string
+	entry internal.ProblemEntry
+	dir   string
+	tests []internal.TestCase
+}
+
+// loadProblemTests reads the tests of problemID. Its errors are meant to be printed as they are.
+func loadProblemTests(problemID string) (*problemTests, error) {
+	state, prob, err := loadProblem(problemID)
+	if err != nil {
+		return nil, err
+	}
+	pt := &problemTests{state: state, id: problemID, entry: prob, dir: internal.ProblemDir(problemID, prob)}
+	if pt.tests, err = internal.LoadTests(pt.dir, prob.Tests); err != nil {
+		return nil, fmt.Errorf("Failed to read the tests of problem %s: %v", problemID, err)
+	}
+	return pt, nil
+}
+
+// position is the number 'cfr test' gives the named test, reading the tests folder again.
+func (pt *problemTests) position(name string) int {
+	tests, _ := internal.LoadTests(pt.dir, pt.entry.Tests)
+	for i, t := range tests {
+		if t.Name == name {
+			return i + 1
+		}
+	}
+	return len(tests)
+}
+
+// saveMeta records the names and kinds of the problem's tests in the problems state.
+func (pt *problemTests) saveMeta(meta []internal.TestCase) error {
+	pt.entry.Tests = meta
+	pt.state.Problems[pt.id] = pt.entry
+	if err := internal.SaveProblemsState(pt.state); err != nil {
+		return fmt.Errorf("Failed to save problems state: %v", err)
+	}
+	return nil
+}
+
+// testIndex turns a 1-based test number into an index into tests.
+func testIndex(tests []internal.TestCase, arg string) (int, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > len(tests) {
+		return 0, fmt.Errorf("No test %s; the problem has %d test(s).", arg, len(tests))
+	}
+	return n - 1, nil
+}
+
+// readTestFiles reads a test's input, and its answer if a second file is given.
+func readTestFiles(paths []string) (internal.TestCase, error) {
+	var tc internal.TestCase
+	input, err := os.ReadFile(paths[0])
+	if err != nil {
+		return tc, err
+	}
+	tc.Input = string(input)
+	if len(paths) > 1 {
+		answer, err := os.ReadFile(paths[1])
+		if err != nil {
+			return tc, err
+		}
+		tc.Output = string(answer)
+	}
+	return tc, nil
+}
+
+// formatTestText writes a test with the input and answer markers.
+func formatTestText(tc internal.TestCase) string {
+	var b strings.Builder
+	for _, section := range []struct{ marker, text string }{
+		{testInputMarker, tc.Input},
+		{testAnswerMarker, tc.Output},
+	} {
+		b.WriteString(section.marker + "\n")
+		if section.text != "" {
+			b.WriteString(strings.TrimRight(section.text, "\r\n") + "\n")
+		}
+	}
+	return b.String()
+}
+
+// parseTestText reads a test written by formatTestText. Text without an answer marker is all
+// input.
+func parseTestText(text string) internal.TestCase {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	var tc internal.TestCase
+	section := &tc.Input
+	var lines []string
+	flush := func() {
+		*section = strings.TrimRight(strings.Join(lines, "\n"), "\n")
+		lines = nil
+	}
+	for _, line := range strings.Split(text, "\n") {
+		switch strings.TrimSpace(line) {
+		case testInputMarker:
+			flush()
+			section = &tc.Input
+		case testAnswerMarker:
+			flush()
+			section = &tc.Output
+		default:
+			lines = append(lines, line)
+		}
+	}
+	flush()
+	return tc
+}
+
+// previewText is the start of a test's first line, with how many lines there are in all.
+func previewText(s string) string {
+	s = strings.TrimRight(s, "\r\n")
+	if s == "" {
+		return "-"
+	}
+	lines := strings.Split(s, "\n")
+	first := strings.TrimRight(lines[0], "\r")
+	if len(first) > testPreviewWidth {
+		first = first[:testPreviewWidth] + "..."
+	}
+	if len(lines) > 1 {
+		return fmt.Sprintf("%s (%d lines)", first, len(lines))
+	}
+	return first
+}
+
+// isTerminal reports whether f is an interactive terminal rather than a file or pipe.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// editText opens text in $VISUAL or $EDITOR and returns what was saved.
+func editText(text string) (string, error) {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+	f, err := os.CreateTemp("", "cfr-test-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(text)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	// The editor may come with arguments, such as "code --wait".
+	argv := append(strings.Fields(editor), f.Name())
+	c := exec.Command(argv[0], argv[1:]...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		return "", fmt.Errorf("Editor %s failed: %v", argv[0], err)
+	}
+	data, err := os.ReadFile(f.Name())
+	return string(data), err
+}
+
+func init() {
+	testsCmd.AddCommand(testsAddCmd, testsListCmd, testsShowCmd, testsEditCmd, testsRmCmd)
+	rootCmd.AddCommand(testsCmd)
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/MihaiZegheru/cfr/internal"
+)
+
+var testSelection string
+var testOnly string
+var testFailed bool
+var testFailFast bool
+
+// selectTests picks the indexes of the tests to run from -t, --only and --failed. Its errors are
+// meant to be printed as they are.
+func selectTests(problemID string, tests []internal.TestCase) ([]int, error) {
+	if len(tests) == 0 {
+		return nil, fmt.Errorf("No tests found for problem %s. Add one with 'cfr tests add %s'.", problemID, problemID)
+	}
+	picked := make([]bool, len(tests))
+	if testSelection == "" {
+		for i := range picked {
+			picked[i] = true
+		}
+	} else if err := parseTestNumbers(testSelection, tests, picked); err != nil {
+		return nil, err
+	}
+	switch testOnly {
+	case "":
+	case internal.TestSample, internal.TestCustom:
+		for i, t := range tests {
+			picked[i] = picked[i] && t.TestKind() == testOnly
+		}
+	default:
+		return nil, fmt.Errorf("Unknown test kind %q. Use --only sample or --only custom.", testOnly)
+	}
+	if testFailed {
+		results, err := internal.LoadTestResults()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read the last test results: %v", err)
+		}
+		for i, t := range tests {
+			r, ok := results[problemID][t.Name]
+			picked[i] = picked[i] && ok && r.Verdict != string(verdictOK)
+		}
+	}
+	var selected []int
+	for i, p := range picked {
+		if p {
+			selected = append(selected, i)
+		}
+	}
+	switch {
+	case len(selected) > 0:
+		return selected, nil
+	case testFailed:
+		return nil, fmt.Errorf("No selected test of problem %s failed last time.", problemID)
+	}
+	return nil, fmt.Errorf("No test of problem %s matches the selection.", problemID)
+}
+
+// parseTestNumbers marks the tests named by a -t value such as "2", "2-4" or "1,3,5-7".
+func parseTestNumbers(spec string, tests []internal.TestCase, picked []bool) error {
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		from, to, isRange := strings.Cut(part, "-")
+		if !isRange {
+			to = from
+		}
+		lo, err := testIndex(tests, strings.TrimSpace(from))
+		if err != nil {
+			return err
+		}
+		hi, err := testIndex(tests, strings.TrimSpace(to))
+		if err != nil {
+			return err
+		}
+		if lo > hi {
+			return fmt.Errorf("Invalid test range %s.", part)
+		}
+		for i := lo; i <= hi; i++ {
+			picked[i] = true
+		}
+	}
+	return nil
+}
+
+// testResult is what is recorded of a test for --failed.
+func testResult(rec testRecord) internal.TestResult {
+	return internal.TestResult{Verdict: string(rec.Verdict), TimeMs: rec.TimeMs, MemoryKB: rec.MemoryKB}
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// verdict is the judgement for a single test, named the way Codeforces reports it.
+type verdict string
+
+const (
+	verdictOK  verdict = "OK"
+	verdictWA  verdict = "Wrong Answer"
+	verdictTLE verdict = "Time Limit Exceeded"
+	verdictMLE verdict = "Memory Limit Exceeded"
+	verdictOLE verdict = "Output Limit Exceeded"
+	verdictRE  verdict = "Runtime Error"
+	// verdictSanitizer is a crash explained by a sanitizer report, only seen in --debug builds.
+	verdictSanitizer verdict = "Sanitizer Error"
+	// verdictPE is only produced by checker and interactor programs.
+	verdictPE verdict = "Presentation Error"
+	// verdictFail means the checker or interactor itself failed, not the solution.
+	verdictFail verdict = "Checker Failed"
+	// verdictCE and verdictExecFail mean the solution could not be compiled or started.
+	verdictCE       verdict = "Compilation Error"
+	verdictExecFail verdict = "Execution Failed"
+)
+
+// runFailureMessage explains a run that ended in TLE, MLE, OLE, RE or a sanitizer report.
+func runFailureMessage(res runResult, lim runLimits) string {
+	switch res.Verdict {
+	case verdictTLE:
+		return fmt.Sprintf("killed after %.2fs, limit %.2fs", res.Elapsed.Seconds(), lim.Time.Seconds())
+	case verdictMLE:
+		return describeMemoryExceeded(res, lim.MemoryMB)
+	case verdictOLE:
+		return fmt.Sprintf("killed after writing more than %d MB", lim.OutputMB)
+	case verdictRE:
+		return res.exitDescription()
+	case verdictSanitizer:
+		report, _ := parseSanitizerReport(res.Stderr)
+		return report.String()
+	}
+	return ""
+}
+
+// describeRunFailure formats the verdict line for a run that ended in TLE, MLE or RE.
+func describeRunFailure(res runResult, lim runLimits) string {
+	return withMessage(res.Verdict, runFailureMessage(res, lim))
+}
+
+// withMessage appends a checker or comparator message to a verdict, if there is one.
+func withMessage(v verdict, msg string) string {
+	if msg == "" {
+		return string(v)
+	}
+	return string(v) + ": " + msg
+}
+
+// printDebugOutput shows what the program wrote to stderr, if anything, under its own heading.
+func printDebugOutput(stderr string, indent string) {
+	stderr = strings.TrimRight(stderr, "\r\n")
+	if stderr == "" {
+		return
+	}
+	fmt.Println(indent + "Debug output:")
+	fmt.Println(stderr)
+}
+
+// verdictSeverity orders verdicts from best to worst, to pick the worst one of a run.
+var verdictSeverity = map[verdict]int{
+	verdictOK:        0,
+	verdictPE:        1,
+	verdictWA:        2,
+	verdictTLE:       3,
+	verdictMLE:       4,
+	verdictOLE:       5,
+	verdictRE:        6,
+	verdictSanitizer: 7,
+	verdictFail:      8,
+	verdictExecFail:  9,
+	verdictCE:        10,
+}
+
+// worseVerdict returns the worse of two verdicts; the empty verdict counts as no verdict yet.
+func worseVerdict(a, b verdict) verdict {
+	if a == "" || verdictSeverity[b] > verdictSeverity[a] {
+		return b
+	}
+	return a
+}
+
+// transcriptTailLines is how much of a failed interaction is printed.
+const transcriptTailLines = 30
+
+// describeInteraction formats the verdict line for an interactive test.
+func describeInteraction(it interaction, lim runLimits) string {
+	return withMessage(it.Verdict, interactionMessage(it, lim))
+}
+
+// interactionMessage explains the verdict of an interactive test.
+func interactionMessage(it interaction, lim runLimits) string {
+	if it.Message == "" && it.Verdict == it.Solution.Verdict {
+		return runFailureMessage(it.Solution, lim)
+	}
+	return it.Message
+}
+
+// printTranscript shows the end of an interaction transcript.
+func printTranscript(transcript string, indent string) {
+	lines := strings.Split(strings.TrimRight(transcript, "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		fmt.Println(indent + "Transcript: (empty)")
+		return
+	}
+	fmt.Println(indent + "Transcript (> solution, < interactor):")
+	if len(lines) > transcriptTailLines {
+		fmt.Printf("... %d earlier line(s) omitted\n", len(lines)-transcriptTailLines)
+		lines = lines[len(lines)-transcriptTailLines:]
+	}
+	fmt.Println(strings.Join(lines, "\n"))
+}
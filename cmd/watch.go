@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/MihaiZegheru/cfr/internal"
+)
+
+const (
+	// watchPollInterval is how often watched files are checked for changes.
+	watchPollInterval = 200 * time.Millisecond
+	// watchDebounce is how long files must stay unchanged before a run starts, so editors that
+	// save in several steps trigger a single run.
+	watchDebounce = 300 * time.Millisecond
+)
+
+// fileStamp is what a watched file is compared by; a missing file has the zero stamp.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// watchTests runs the tests of problemID, then reruns them whenever the solution, a local header
+// it includes, the tests or the checker/interactor change, until cfr is interrupted. A run still
+// in progress when a change arrives is killed.
+func watchTests(problemID string) {
+	for {
+		files := watchedFiles(problemID)
+		stamps := stampFiles(files)
+		clearScreen()
+		fmt.Printf("[%s] Testing %s; watching %d file(s), press Ctrl-C to stop.\n\n", time.Now().Format("15:04:05"), problemID, len(files))
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			rep, _ := newTestReporter("text")
+			runTests(problemID, rep)
+			rep.finish()
+		}()
+
+		finished := false
+		ticker := time.NewTicker(watchPollInterval)
+		for changed := false; !changed; {
+			select {
+			case <-done:
+				if !finished {
+					finished = true
+					fmt.Println("\nWaiting for changes...")
+				}
+				done = nil
+			case <-ticker.C:
+				changed = filesChanged(files, stamps)
+			}
+		}
+		ticker.Stop()
+		waitUntilSettled(files)
+		if !finished {
+			abortRunning()
+			<-done
+			resumeRunning()
+			fmt.Println("\nChanged; the run above was stopped early.")
+		}
+	}
+}
+
+// watchedFiles lists the files whose changes trigger a new run. Local includes are found again
+// on every run, so newly included headers are picked up.
+func watchedFiles(problemID string) []string {
+	files := []string{internal.StatePath(), internal.ConfigPath()}
+	pc, err := loadProblemContext(problemID)
+	if err != nil {
+		return files
+	}
+	source := pc.sourceFile()
+	files = append(files, source)
+	if pc.Language.LocalIncludes {
+		files = append(files, localIncludes(source, pc.Build.IncludePaths, map[string]bool{source: true})...)
+	}
+	for _, name := range []string{"checker", "interactor"} {
+		if helper := findHelperSource(pc.Config, pc.Dir, name); helper != "" {
+			files = append(files, helper)
+		}
+	}
+	for _, name := range []string{pc.Config.Problem(problemID).Checker, pc.Config.Problem(problemID).Interactor} {
+		if path := filepath.Join(pc.Dir, name); name != "" && fileExists(path) {
+			files = append(files, path)
+		}
+	}
+	if customTest {
+		return append(files, filepath.Join(pc.Dir, "in.txt"))
+	}
+	// The folder changes when tests are added or removed, the files when a test is edited.
+	files = append(files, filepath.Join(pc.Dir, internal.TestsDir))
+	for _, t := range pc.Tests {
+		in, ans := internal.TestFiles(pc.Dir, t.Name)
+		files = append(files, in, ans)
+	}
+	return files
+}
+
+func stampFiles(files []string) map[string]fileStamp {
+	stamps := make(map[string]fileStamp, len(files))
+	for _, f := range files {
+		if fi, err := os.Stat(f); err == nil {
+			stamps[f] = fileStamp{fi.ModTime(), fi.Size()}
+		} else {
+			stamps[f] = fileStamp{}
+		}
+	}
+	return stamps
+}
+
+func filesChanged(files []string, stamps map[string]fileStamp) bool {
+	now := stampFiles(files)
+	for _, f := range files {
+		if now[f] != stamps[f] {
+			return true
+		}
+	}
+	return false
+}
+
+// waitUntilSettled returns once files have not changed for watchDebounce.
+func waitUntilSettled(files []string) {
+	stamps := stampFiles(files)
+	quiet := time.Now()
+	for time.Since(quiet) < watchDebounce {
+		time.Sleep(watchPollInterval / 2)
+		if filesChanged(files, stamps) {
+			stamps, quiet = stampFiles(files), time.Now()
+		}
+	}
+}
+
+// clearScreen wipes the terminal before a new run; without a terminal a separator is printed.
+func clearScreen() {
+	if useColor() {
+		fmt.Print("\033[H\033[2J")
+		return
+	}
+	fmt.Println("----------------------------------------")
+}
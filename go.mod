@@ -2,11 +2,13 @@ module github.com/MihaiZegheru/cfr
 
 go 1.23.0
 
-require github.com/spf13/cobra v1.8.0
+require (
+	github.com/JohannesKaufmann/html-to-markdown v1.6.0
+	github.com/PuerkitoBio/goquery v1.10.3
+	github.com/spf13/cobra v1.8.0
+)
 
 require (
-	github.com/JohannesKaufmann/html-to-markdown v1.6.0 // indirect
-	github.com/PuerkitoBio/goquery v1.10.3 // indirect
 	github.com/andybalholm/cascadia v1.3.3 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
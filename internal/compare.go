@@ -0,0 +1,190 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Comparator checks a contestant's output against the expected answer. On mismatch it returns
+// false and a testlib-style message such as "3rd token differs: expected 5, found 4".
+type Comparator func(expected, actual string) (bool, string)
+
+// DefaultComparator is the comparison cfr has always used: lines with trailing whitespace
+// trimmed and trailing blank lines ignored.
+const DefaultComparator = "lines"
+
+// defaultFloatEps is the tolerance used by "float" when none is given.
+const defaultFloatEps = 1e-6
+
+// ComparatorHelp lists the comparator names accepted by LookupComparator.
+var ComparatorHelp = []string{
+	"lines      compare line by line, ignoring trailing spaces and trailing blank lines (default)",
+	"exact      compare byte for byte",
+	"tokens     compare whitespace-separated tokens",
+	"nocase     compare tokens case-insensitively (YES/yes)",
+	"unordered  compare tokens as a multiset, in any order",
+	"float[:E]  compare tokens, numbers within absolute or relative error E (default 1e-6)",
+}
+
+// LookupComparator returns the comparator with the given name; "float" accepts an optional
+// tolerance suffix such as "float:1e-9". An empty name selects DefaultComparator.
+func LookupComparator(name string) (Comparator, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case "", DefaultComparator:
+		return compareLines, nil
+	case "exact":
+		return compareExact, nil
+	case "tokens":
+		return compareTokens, nil
+	case "nocase":
+		return compareNoCase, nil
+	case "unordered":
+		return compareUnordered, nil
+	}
+	if name == "float" || strings.HasPrefix(name, "float:") {
+		eps := defaultFloatEps
+		if _, arg, ok := strings.Cut(name, ":"); ok {
+			v, err := strconv.ParseFloat(arg, 64)
+			if err != nil || v < 0 {
+				return nil, fmt.Errorf("invalid float tolerance %q", arg)
+			}
+			eps = v
+		}
+		return floatComparator(eps), nil
+	}
+	return nil, fmt.Errorf("unknown checker %q", name)
+}
+
+// NormalizeLines trims trailing whitespace on every line and drops trailing blank lines.
+func NormalizeLines(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func compareLines(expected, actual string) (bool, string) {
+	exp := strings.Split(NormalizeLines(expected), "\n")
+	act := strings.Split(NormalizeLines(actual), "\n")
+	for i := 0; i < len(exp) && i < len(act); i++ {
+		if exp[i] != act[i] {
+			return false, fmt.Sprintf("%s line differs: expected %s, found %s", ordinal(i+1), quote(exp[i]), quote(act[i]))
+		}
+	}
+	if len(exp) != len(act) {
+		return false, fmt.Sprintf("expected %d lines, found %d", len(exp), len(act))
+	}
+	return true, fmt.Sprintf("%d lines", len(exp))
+}
+
+func compareExact(expected, actual string) (bool, string) {
+	if expected == actual {
+		return true, fmt.Sprintf("%d bytes", len(expected))
+	}
+	n := 0
+	for n < len(expected) && n < len(actual) && expected[n] == actual[n] {
+		n++
+	}
+	return false, fmt.Sprintf("outputs differ at byte %d", n+1)
+}
+
+func compareTokens(expected, actual string) (bool, string) {
+	return compareTokenwise(expected, actual, func(e, a string) bool { return e == a })
+}
+
+func compareNoCase(expected, actual string) (bool, string) {
+	return compareTokenwise(expected, actual, strings.EqualFold)
+}
+
+func floatComparator(eps float64) Comparator {
+	return func(expected, actual string) (bool, string) {
+		return compareTokenwise(expected, actual, func(e, a string) bool {
+			ev, err1 := strconv.ParseFloat(e, 64)
+			av, err2 := strconv.ParseFloat(a, 64)
+			if err1 != nil || err2 != nil {
+				return e == a
+			}
+			return floatsClose(ev, av, eps)
+		})
+	}
+}
+
+// floatsClose follows testlib's doubleCompare: absolute or relative error within eps.
+func floatsClose(expected, actual, eps float64) bool {
+	if math.IsNaN(expected) || math.IsNaN(actual) {
+		return math.IsNaN(expected) && math.IsNaN(actual)
+	}
+	if math.IsInf(expected, 0) || math.IsInf(actual, 0) {
+		return expected == actual
+	}
+	diff := math.Abs(expected - actual)
+	return diff <= eps || diff <= eps*math.Abs(expected)
+}
+
+func compareUnordered(expected, actual string) (bool, string) {
+	exp := strings.Fields(expected)
+	act := strings.Fields(actual)
+	if len(exp) != len(act) {
+		return false, fmt.Sprintf("expected %d tokens, found %d", len(exp), len(act))
+	}
+	sort.Strings(exp)
+	sort.Strings(act)
+	for i := range exp {
+		if exp[i] != act[i] {
+			return false, fmt.Sprintf("token sets differ: expected %s, found %s", quote(exp[i]), quote(act[i]))
+		}
+	}
+	return true, fmt.Sprintf("%d tokens", len(exp))
+}
+
+func compareTokenwise(expected, actual string, equal func(e, a string) bool) (bool, string) {
+	exp := strings.Fields(expected)
+	act := strings.Fields(actual)
+	for i := 0; i < len(exp) && i < len(act); i++ {
+		if !equal(exp[i], act[i]) {
+			return false, fmt.Sprintf("%s token differs: expected %s, found %s", ordinal(i+1), quote(exp[i]), quote(act[i]))
+		}
+	}
+	if len(act) < len(exp) {
+		return false, fmt.Sprintf("unexpected end of output: expected %d tokens, found %d", len(exp), len(act))
+	}
+	if len(act) > len(exp) {
+		return false, fmt.Sprintf("extra output: expected %d tokens, found %d", len(exp), len(act))
+	}
+	return true, fmt.Sprintf("%d tokens", len(exp))
+}
+
+// ordinal formats n as "1st", "2nd", "3rd", "4th", ... like testlib's englishEnding.
+func ordinal(n int) string {
+	if n%100/10 != 1 {
+		switch n % 10 {
+		case 1:
+			return fmt.Sprintf("%dst", n)
+		case 2:
+			return fmt.Sprintf("%dnd", n)
+		case 3:
+			return fmt.Sprintf("%drd", n)
+		}
+	}
+	return fmt.Sprintf("%dth", n)
+}
+
+// quote shortens long values and makes empty or whitespace-only ones visible.
+func quote(s string) string {
+	const max = 64
+	if len(s) > max {
+		s = s[:max] + "..."
+	}
+	if strings.TrimSpace(s) == "" || strings.ContainsAny(s, " \t") {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const configFile = "config.json"
+
+// ProblemConfig holds per-problem overrides from the "problems" section of config.json.
+type ProblemConfig struct {
+	TimeLimitMs   int `json:"time_limit_ms,omitempty"`
+	MemoryLimitMB int `json:"memory_limit_mb,omitempty"`
+	OutputLimitMB int `json:"output_limit_mb,omitempty"`
+	// Checker names the output comparator, see LookupComparator.
+	Checker string `json:"checker,omitempty"`
+	// Interactor names an interactor source in the problem folder, making the problem interactive.
+	Interactor string `json:"interactor,omitempty"`
+	// Profile names the build profile for the problem's solution.
+	Profile string `json:"profile,omitempty"`
+}
+
+// Config mirrors .cfr/config.json.
+type Config struct {
+	DefaultLanguage string                   `json:"default_language"`
+	Languages       map[string]string        `json:"languages"`
+	Executables     map[string]string        `json:"executables"`
+	Problems        map[string]ProblemConfig `json:"problems,omitempty"`
+	// OutputLimitMB caps what a solution may write to stdout and stderr together.
+	OutputLimitMB int `json:"output_limit_mb,omitempty"`
+	// Sandbox isolates solutions and generators: "off", "on" or "seccomp".
+	Sandbox string `json:"sandbox,omitempty"`
+	// Debug builds solutions with sanitizers by default, as if --debug were always given.
+	Debug bool `json:"debug,omitempty"`
+	// Profiles are user-defined build profiles; DefaultProfile is used for problems without one.
+	Profiles       map[string]BuildProfile `json:"profiles,omitempty"`
+	DefaultProfile string                  `json:"default_profile,omitempty"`
+	// CustomLanguages defines new languages, or replaces built-in ones, see Language.
+	CustomLanguages map[string]Language `json:"custom_languages,omitempty"`
+}
+
+func getConfigPath() string {
+	return filepath.Join(cfrDir, configFile)
+}
+
+// LoadConfig reads .cfr/config.json. A missing file yields an empty config and the error from os.Open.
+func LoadConfig() (Config, error) {
+	var cfg Config
+	f, err := os.Open(getConfigPath())
+	if err != nil {
+		return cfg, err
+	}
+	defer f.Close()
+	err = json.NewDecoder(f).Decode(&cfg)
+	return cfg, err
+}
+
+func SaveConfig(cfg Config) error {
+	f, err := os.Create(getConfigPath())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(cfg)
+}
+
+// LanguageFor returns the lowercased language configured for a problem, falling back to
+// default_language and then to cpp.
+func (c Config) LanguageFor(problemID string) string {
+	if l, ok := c.Languages[problemID]; ok && l != "" {
+		return strings.ToLower(l)
+	}
+	if c.DefaultLanguage != "" {
+		return strings.ToLower(c.DefaultLanguage)
+	}
+	return "cpp"
+}
+
+// Problem returns the overrides configured for a problem, or the zero value.
+func (c Config) Problem(problemID string) ProblemConfig {
+	return c.Problems[problemID]
+}
+
+// ConfigPath is the config file cfr reads and writes.
+func ConfigPath() string {
+	return getConfigPath()
+}
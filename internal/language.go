@@ -0,0 +1,222 @@
+package internal
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Language describes how solutions in one language are stored, built and run. Compile and Run
+// are command templates; each element may contain these placeholders:
+//
+//	{compiler}  the compiler or interpreter (Compiler, overridden by "executables" or a build profile)
+//	{source}    the source file
+//	{binary}    the compiled program
+//	{build}     a build directory of the program's own inside the problem directory
+//	{dir}       the problem directory
+//	{runtime}   the virtual machine that runs the compiled program (Runtime)
+//	{flags}     the build profile's flags, expanded into separate arguments
+//	{run_flags} the profile's runtime flags, expanded into separate arguments
+//	{defines}   the profile's defines as -D<define> arguments
+//	{includes}  the profile's include paths as -I<path> arguments
+//
+// In Compile, paths are relative to the workspace; in Run and Startup, which start in the
+// problem directory, they are relative to that directory. Only builds that write {binary} are
+// kept in the build cache.
+type Language struct {
+	// Name is the key the language is registered under; it is filled in by Config.Language.
+	Name string `json:"-"`
+	// Aliases are other names accepted for the language, such as "c++" for cpp.
+	Aliases []string `json:"aliases,omitempty"`
+	// Extension is the source file extension, including the dot. OtherExtensions are also
+	// recognised for checkers, interactors and generators.
+	Extension       string   `json:"extension"`
+	OtherExtensions []string `json:"other_extensions,omitempty"`
+	// SourceFile is the solution's file name in the problem directory, "main"+Extension by default.
+	SourceFile string `json:"source_file,omitempty"`
+	// Compiler is the default compiler or interpreter.
+	Compiler string `json:"compiler,omitempty"`
+	// Flags are the compiler flags of the release build profile.
+	Flags []string `json:"flags,omitempty"`
+	// Runtime and RunFlags are the defaults for {runtime} and {run_flags}.
+	Runtime  string   `json:"runtime,omitempty"`
+	RunFlags []string `json:"run_flags,omitempty"`
+	// CompileAs is the name the source is copied to inside {build} before compiling, for
+	// languages such as Java whose public class has to match the file name.
+	CompileAs string `json:"compile_as,omitempty"`
+	// Compile is empty for interpreted languages.
+	Compile []string `json:"compile,omitempty"`
+	// Run is empty for languages that cfr can create files for but not test.
+	Run []string `json:"run,omitempty"`
+	// Startup, if set, only starts the runtime. It is timed before the tests, and that much is
+	// reported as startup time and left out of the solution's time.
+	Startup []string `json:"startup,omitempty"`
+	// LocalIncludes marks C-like languages whose `#include "..."` headers are part of the build.
+	LocalIncludes bool `json:"local_includes,omitempty"`
+	// NoAddressLimit is for runtimes such as Go, node or the JVM that reserve far more address space
+	// than they use; the memory limit is then only checked against peak memory use.
+	NoAddressLimit bool `json:"no_address_limit,omitempty"`
+}
+
+// SourceFileName is the name of the solution file in a problem directory.
+func (l Language) SourceFileName() string {
+	if l.SourceFile != "" {
+		return l.SourceFile
+	}
+	return "main" + l.Extension
+}
+
+// Compiled reports whether the language needs a compile step before running.
+func (l Language) Compiled() bool {
+	return len(l.Compile) > 0
+}
+
+// Runnable reports whether cfr knows how to run the language.
+func (l Language) Runnable() bool {
+	return len(l.Run) > 0
+}
+
+var cLikeCompile = []string{"{compiler}", "{flags}", "{defines}", "{includes}", "{source}", "-o", "{binary}"}
+
+// builtinLanguages are always available; "custom_languages" in config.json adds to them or
+// replaces them by name.
+var builtinLanguages = map[string]Language{
+	"cpp": {
+		Aliases:         []string{"c++"},
+		Extension:       ".cpp",
+		OtherExtensions: []string{".cc", ".cxx"},
+		Compiler:        "g++",
+		Flags:           []string{"-O2", "-std=c++17"},
+		Compile:         cLikeCompile,
+		Run:             []string{"{binary}"},
+		LocalIncludes:   true,
+	},
+	"c": {
+		Extension:     ".c",
+		Compiler:      "gcc",
+		Flags:         []string{"-O2"},
+		Compile:       cLikeCompile,
+		Run:           []string{"{binary}"},
+		LocalIncludes: true,
+	},
+	"go": {
+		Extension: ".go",
+		Compiler:  "go",
+		Compile:   []string{"{compiler}", "build", "{flags}", "-o", "{binary}", "{source}"},
+		Run:       []string{"{binary}"},
+		// The Go runtime reserves more address space at startup than most memory limits allow.
+		NoAddressLimit: true,
+	},
+	"python": {
+		Aliases:   []string{"py"},
+		Extension: ".py",
+		Compiler:  "python",
+		Run:       []string{"{compiler}", "{flags}", "{source}"},
+	},
+	"rust": {
+		Extension: ".rs",
+		Compiler:  "rustc",
+		// What Codeforces compiles Rust 2021 with.
+		Flags:   []string{"--edition=2021", "-O", "--cfg", "ONLINE_JUDGE"},
+		Compile: []string{"{compiler}", "{flags}", "--color", "never", "{source}", "-o", "{binary}"},
+		Run:     []string{"{binary}"},
+	},
+	"java": {
+		Extension: ".java",
+		Compiler:  "javac",
+		Flags:     []string{"-encoding", "UTF-8"},
+		Runtime:   "java",
+		// The JVM flags Codeforces runs solutions with, apart from -Xmx.
+		RunFlags:       []string{"-XX:NewRatio=5", "-Xms8m", "-Xss64m", "-DONLINE_JUDGE=true"},
+		CompileAs:      "Main.java",
+		Compile:        []string{"{compiler}", "{flags}", "-d", "{build}", "{source}"},
+		Run:            []string{"{runtime}", "{run_flags}", "-cp", "{build}", "Main"},
+		Startup:        []string{"{runtime}", "{run_flags}", "-version"},
+		NoAddressLimit: true,
+	},
+}
+
+// languages merges the built-in languages with the custom ones from config.
+func (c Config) languages() map[string]Language {
+	all := make(map[string]Language, len(builtinLanguages)+len(c.CustomLanguages))
+	for name, l := range builtinLanguages {
+		all[name] = l
+	}
+	for name, l := range c.CustomLanguages {
+		all[strings.ToLower(name)] = l
+	}
+	return all
+}
+
+// Language looks a language up by name or alias, case-insensitively.
+func (c Config) Language(name string) (Language, bool) {
+	name = strings.ToLower(name)
+	all := c.languages()
+	if l, ok := all[name]; ok {
+		l.Name = name
+		return l, true
+	}
+	for key, l := range all {
+		for _, alias := range l.Aliases {
+			if strings.ToLower(alias) == name {
+				l.Name = key
+				return l, true
+			}
+		}
+	}
+	return Language{}, false
+}
+
+// LanguageNames lists the registered languages in alphabetical order.
+func (c Config) LanguageNames() []string {
+	var names []string
+	for name := range c.languages() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LanguageForFile guesses the language of a source file from its extension, preferring built-in
+// languages, and returns "" if no runnable language matches.
+func (c Config) LanguageForFile(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	match := func(l Language) bool {
+		if !l.Runnable() {
+			return false
+		}
+		for _, e := range append([]string{l.Extension}, l.OtherExtensions...) {
+			if strings.ToLower(e) == ext {
+				return true
+			}
+		}
+		return false
+	}
+	for _, name := range c.LanguageNames() {
+		if l, ok := builtinLanguages[name]; ok && match(l) {
+			if _, overridden := c.CustomLanguages[name]; !overridden {
+				return name
+			}
+		}
+	}
+	for _, name := range c.LanguageNames() {
+		if l, _ := c.Language(name); match(l) {
+			return name
+		}
+	}
+	return ""
+}
+
+// SourceFileNames lists the solution file names of every registered language, without duplicates.
+func (c Config) SourceFileNames() []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, name := range c.LanguageNames() {
+		l, _ := c.Language(name)
+		if f := l.SourceFileName(); !seen[f] {
+			seen[f] = true
+			names = append(names, f)
+		}
+	}
+	return names
+}
@@ -0,0 +1,37 @@
+package internal
+
+// LanguageBuild is how one language is built in a build profile. Empty fields are inherited from
+// the built-in profile of the same name, and then from the language.
+type LanguageBuild struct {
+	// Compiler is the compiler, or the interpreter for interpreted languages.
+	Compiler string   `json:"compiler,omitempty"`
+	Flags    []string `json:"flags,omitempty"`
+	// Defines are passed as -D<define> to C and C++ compilers.
+	Defines []string `json:"defines,omitempty"`
+	// IncludePaths are passed as -I<path> to C and C++ compilers, relative to the workspace.
+	IncludePaths []string `json:"include_paths,omitempty"`
+	// Runtime and RunFlags start compiled programs that need a virtual machine, such as java
+	// with its -Xss and -Xmx flags.
+	Runtime  string   `json:"runtime,omitempty"`
+	RunFlags []string `json:"run_flags,omitempty"`
+	// Env is set for the compiler and for every run of the program.
+	Env map[string]string `json:"env,omitempty"`
+}
+
+// BuildProfile maps language names to how they are built.
+type BuildProfile map[string]LanguageBuild
+
+// ProfileFor returns the build profile chosen for a problem in config.json, or "" when none is:
+// the problem's own "profile", then "default_profile", then "debug".
+func (c Config) ProfileFor(problemID string) string {
+	if p := c.Problem(problemID).Profile; p != "" {
+		return p
+	}
+	if c.DefaultProfile != "" {
+		return c.DefaultProfile
+	}
+	if c.Debug {
+		return "debug"
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+const resultsFile = "results.json"
+
+// TestResult is how a test went the last time it was run.
+type TestResult struct {
+	Verdict  string `json:"verdict"`
+	TimeMs   int64  `json:"time_ms"`
+	MemoryKB int64  `json:"memory_kb"`
+}
+
+// TestResults holds the last result of every test that has run, by problem ID and then by test
+// name.
+type TestResults map[string]map[string]TestResult
+
+func getResultsPath() string {
+	if _, err := os.Stat(cfrDir); err == nil {
+		return filepath.Join(cfrDir, resultsFile)
+	}
+	return resultsFile
+}
+
+// LoadTestResults reads the recorded test results; a workspace where nothing has run yet has
+// none.
+func LoadTestResults() (TestResults, error) {
+	results := TestResults{}
+	data, err := os.ReadFile(getResultsPath())
+	if os.IsNotExist(err) {
+		return results, nil
+	}
+	if err != nil {
+		return results, err
+	}
+	err = json.Unmarshal(data, &results)
+	return results, err
+}
+
+func SaveTestResults(results TestResults) error {
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(getResultsPath(), data, 0644)
+}
+
+// RecordTestResults stores the results of one run of problemID's tests. Results of tests that
+// no longer exist are dropped; tests that did not run keep their previous result.
+func RecordTestResults(problemID string, tests []TestCase, run map[string]TestResult) error {
+	results, err := LoadTestResults()
+	if err != nil {
+		results = TestResults{}
+	}
+	kept := map[string]TestResult{}
+	for _, t := range tests {
+		if r, ok := run[t.Name]; ok {
+			kept[t.Name] = r
+		} else if r, ok := results[problemID][t.Name]; ok {
+			kept[t.Name] = r
+		}
+	}
+	results[problemID] = kept
+	return SaveTestResults(results)
+}
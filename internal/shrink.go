@@ -0,0 +1,212 @@
+package internal
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Shrinker greedily minimizes a failing test input. It removes chunks of lines, removes tokens
+// and shrinks numbers, keeping every change after which StillFails reports that the failure is
+// preserved, until no change helps or Stop returns true.
+type Shrinker struct {
+	// StillFails reports whether a candidate input still reproduces the failure.
+	StillFails func(input string) bool
+	// OnProgress, if set, is called with every smaller failing input that is found.
+	OnProgress func(input string)
+	// Stop, if set, ends the search early, e.g. when a time or run budget is used up.
+	Stop func() bool
+}
+
+// Shrink returns the smallest failing input found, starting from input which must fail.
+func (s Shrinker) Shrink(input string) string {
+	lines := splitInputLines(input)
+	for changed := true; changed && !s.stopped(); {
+		changed = false
+		for _, pass := range []func([]string) ([]string, bool){s.removeLineChunks, s.removeTokens, s.shrinkNumbers} {
+			if next, ok := pass(lines); ok {
+				lines, changed = next, true
+			}
+		}
+	}
+	return joinInputLines(lines)
+}
+
+func (s Shrinker) stopped() bool {
+	return s.Stop != nil && s.Stop()
+}
+
+// try tests the candidates in order and returns the first one that still fails.
+func (s Shrinker) try(candidates ...[]string) ([]string, bool) {
+	for _, c := range candidates {
+		if s.stopped() {
+			return nil, false
+		}
+		in := joinInputLines(c)
+		if s.StillFails(in) {
+			if s.OnProgress != nil {
+				s.OnProgress(in)
+			}
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// removeLineChunks drops runs of lines, halving the run length down to single lines. When the
+// input starts with a lone count (the usual "t" line), variants with the count lowered to match
+// are tried first so the test stays well-formed.
+func (s Shrinker) removeLineChunks(lines []string) ([]string, bool) {
+	changed := false
+	first := 0
+	if _, ok := leadingCount(lines); ok {
+		first = 1
+	}
+	for k := (len(lines) - first) / 2; k >= 1; k /= 2 {
+		for i := first; i+k <= len(lines) && !s.stopped(); {
+			base := append(append([]string{}, lines[:i]...), lines[i+k:]...)
+			var candidates [][]string
+			if c, ok := leadingCount(lines); ok {
+				for _, d := range []int{1, k} {
+					if c-d >= 1 {
+						candidates = append(candidates, withLine(base, 0, strconv.Itoa(c-d)))
+					}
+				}
+			}
+			candidates = append(candidates, base)
+			if next, ok := s.try(candidates...); ok {
+				lines, changed = next, true
+				continue
+			}
+			i += k
+		}
+	}
+	return lines, changed
+}
+
+// removeTokens drops single tokens from lines with several of them. If the previous line holds
+// the number of tokens on this line (an "n" before an array), it is decremented as well.
+func (s Shrinker) removeTokens(lines []string) ([]string, bool) {
+	changed := false
+	for li := 0; li < len(lines) && !s.stopped(); li++ {
+		for j := len(strings.Fields(lines[li])) - 1; j >= 0 && !s.stopped(); j-- {
+			tokens := strings.Fields(lines[li])
+			if len(tokens) < 2 || j >= len(tokens) {
+				continue
+			}
+			rest := append(append([]string{}, tokens[:j]...), tokens[j+1:]...)
+			base := withLine(lines, li, strings.Join(rest, " "))
+			var candidates [][]string
+			if pi := arrayCount(lines, li); pi >= 0 {
+				prev := strings.Fields(lines[li-1])
+				prev[pi] = strconv.Itoa(len(tokens) - 1)
+				candidates = append(candidates, withLine(base, li-1, strings.Join(prev, " ")))
+			}
+			candidates = append(candidates, base)
+			if next, ok := s.try(candidates...); ok {
+				lines, changed = next, true
+			}
+		}
+	}
+	return lines, changed
+}
+
+// shrinkNumbers moves integer tokens towards zero, trying 1, 0, half the value and one step closer.
+// Counts are left alone: they only go down together with what they count, in the other passes.
+func (s Shrinker) shrinkNumbers(lines []string) ([]string, bool) {
+	changed := false
+	for li := 0; li < len(lines) && !s.stopped(); li++ {
+		for j := range strings.Fields(lines[li]) {
+			for !s.stopped() && !isCount(lines, li, j) {
+				tokens := strings.Fields(lines[li])
+				v, err := strconv.ParseInt(tokens[j], 10, 64)
+				if err != nil || v == 0 {
+					break
+				}
+				var candidates [][]string
+				for _, c := range smallerInts(v) {
+					tokens[j] = strconv.FormatInt(c, 10)
+					candidates = append(candidates, withLine(lines, li, strings.Join(tokens, " ")))
+				}
+				next, ok := s.try(candidates...)
+				if !ok {
+					break
+				}
+				lines, changed = next, true
+			}
+		}
+	}
+	return lines, changed
+}
+
+// smallerInts lists values closer to zero than v, most aggressive first.
+func smallerInts(v int64) []int64 {
+	sign := int64(1)
+	if v < 0 {
+		sign = -1
+	}
+	abs := v * sign
+	var out []int64
+	seen := map[int64]bool{}
+	for _, c := range []int64{1, 0, abs / 2, abs - 1} {
+		if c < abs && !seen[c] {
+			seen[c] = true
+			out = append(out, c*sign)
+		}
+	}
+	return out
+}
+
+// leadingCount returns the value of the first line if it is a single positive integer.
+func leadingCount(lines []string) (int, bool) {
+	if len(lines) < 2 {
+		return 0, false
+	}
+	c, err := strconv.Atoi(strings.TrimSpace(lines[0]))
+	return c, err == nil && c > 0
+}
+
+// arrayCount returns the index of the token on the line before li that holds the number of tokens
+// on line li (an "n" before an array), the last one if several do, or -1 if none does.
+func arrayCount(lines []string, li int) int {
+	if li == 0 {
+		return -1
+	}
+	n := strconv.Itoa(len(strings.Fields(lines[li])))
+	prev := strings.Fields(lines[li-1])
+	for pi := len(prev) - 1; pi >= 0; pi-- {
+		if prev[pi] == n {
+			return pi
+		}
+	}
+	return -1
+}
+
+// isCount reports whether token j of line li is a count the shrinker keeps in step with the
+// input: the leading test count or an "n" before an array.
+func isCount(lines []string, li, j int) bool {
+	if _, ok := leadingCount(lines); ok && li == 0 && j == 0 {
+		return true
+	}
+	return li+1 < len(lines) && arrayCount(lines, li+1) == j
+}
+
+func withLine(lines []string, i int, line string) []string {
+	out := append([]string{}, lines...)
+	out[i] = line
+	return out
+}
+
+func splitInputLines(input string) []string {
+	input = strings.TrimRight(strings.ReplaceAll(input, "\r\n", "\n"), "\n")
+	if input == "" {
+		return nil
+	}
+	return strings.Split(input, "\n")
+}
+
+func joinInputLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
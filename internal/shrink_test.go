@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// parseArray reads "n" followed by a line of n integers, and reports whether input has that form.
+func parseArray(input string) ([]int, bool) {
+	lines := splitInputLines(input)
+	if len(lines) != 2 {
+		return nil, false
+	}
+	n, err := strconv.Atoi(lines[0])
+	if err != nil || n < 1 {
+		return nil, false
+	}
+	var a []int
+	for _, f := range strings.Fields(lines[1]) {
+		v, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, false
+		}
+		a = append(a, v)
+	}
+	return a, len(a) == n
+}
+
+func TestShrinkKeepsArrayCountInStep(t *testing.T) {
+	s := Shrinker{StillFails: func(input string) bool {
+		// Only a well-formed array with a 7 in it triggers the bug.
+		a, ok := parseArray(input)
+		return ok && slices.Contains(a, 7)
+	}}
+	got := s.Shrink("9\n2 5 2 8 8 8 7 4 2\n")
+	if got != "1\n7\n" {
+		t.Errorf("Shrink = %q, want %q", got, "1\n7\n")
+	}
+}
+
+func TestShrinkKeepsInputWellFormed(t *testing.T) {
+	// Like a brute force that ignores n, this oracle accepts malformed inputs, so only the
+	// shrinker can keep n in step with the array.
+	s := Shrinker{StillFails: func(input string) bool {
+		lines := splitInputLines(input)
+		return len(lines) == 2 && slices.Contains(strings.Fields(lines[1]), "7")
+	}}
+	got := s.Shrink("9\n2 5 2 8 8 8 7 4 2\n")
+	if a, ok := parseArray(got); !ok || !slices.Contains(a, 7) {
+		t.Errorf("Shrink = %q, want a well-formed array with a 7", got)
+	}
+}
+
+func TestShrinkNumbersSkipsCounts(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		li, j int
+		want  bool
+	}{
+		{"3\n1\n2\n3\n", 0, 0, true},
+		{"3\n5\n2\n3\n", 1, 0, false},
+		{"4 10\n1 2 3 4\n", 0, 0, true},
+		{"4 10\n1 2 3 4\n", 0, 1, false},
+		{"4 10\n1 2 3 4\n", 1, 3, false},
+	} {
+		if got := isCount(splitInputLines(tc.input), tc.li, tc.j); got != tc.want {
+			t.Errorf("isCount(%q, %d, %d) = %v, want %v", tc.input, tc.li, tc.j, got, tc.want)
+		}
+	}
+}
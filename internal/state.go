@@ -18,15 +18,52 @@ func getStatePath() string {
 	return stateFile
 }
 
+// Test kinds. Sample tests are scraped from the statement and replaced on every reload; custom
+// tests are added with 'cfr tests add' and kept.
+const (
+	TestSample = "sample"
+	TestCustom = "custom"
+)
+
 type TestCase struct {
-	Input  string `json:"input"`
-	Output string `json:"output"`
+	// Name is the base name of the test's files in the problem's tests folder, e.g. "01" for
+	// tests/01.in and tests/01.ans.
+	Name string `json:"name,omitempty"`
+	// Input and Output are read from the test's files. The state only holds them inline when it
+	// was written before tests were stored as files, and is then migrated on load.
+	Input  string `json:"input,omitempty"`
+	Output string `json:"output,omitempty"`
+	// Kind is TestSample or TestCustom; tests saved before kinds existed are samples.
+	Kind string `json:"kind,omitempty"`
+}
+
+// TestKind is the test's kind, TestSample if none is recorded.
+func (t TestCase) TestKind() string {
+	if t.Kind == "" {
+		return TestSample
+	}
+	return t.Kind
 }
 
 type ProblemEntry struct {
 	URL   string     `json:"url"`
 	Name  string     `json:"name"`
 	Tests []TestCase `json:"tests"`
+	// TimeLimitMs is the "time limit per test" scraped from the statement, 0 if unknown.
+	TimeLimitMs int `json:"time_limit_ms,omitempty"`
+	// MemoryLimitMB is the "memory limit per test" scraped from the statement, 0 if unknown.
+	MemoryLimitMB int `json:"memory_limit_mb,omitempty"`
+}
+
+// CustomTests are the problem's tests that were not scraped from the statement.
+func (p ProblemEntry) CustomTests() []TestCase {
+	var tests []TestCase
+	for _, t := range p.Tests {
+		if t.TestKind() == TestCustom {
+			tests = append(tests, t)
+		}
+	}
+	return tests
 }
 
 type ProblemsState struct {
@@ -34,7 +71,15 @@ type ProblemsState struct {
 	Problems  map[string]ProblemEntry `json:"problems"`
 }
 
+// SaveProblemsState writes the state. Tests held inline, such as freshly scraped samples, are
+// first written to files in their problem directories.
 func SaveProblemsState(state ProblemsState) error {
+	for id, p := range state.Problems {
+		if err := storeInlineTests(id, &p); err != nil {
+			return err
+		}
+		state.Problems[id] = p
+	}
 	f, err := os.Create(getStatePath())
 	if err != nil {
 		return err
@@ -54,6 +99,31 @@ func LoadProblemsState() (ProblemsState, error) {
 	if err != nil {
 		return state, err
 	}
-	err = json.Unmarshal(data, &state)
+	if err = json.Unmarshal(data, &state); err != nil {
+		return state, err
+	}
+	// Move tests from a state file that still holds them inline to files.
+	migrate := false
+	for _, p := range state.Problems {
+		for _, t := range p.Tests {
+			migrate = migrate || t.Name == ""
+		}
+	}
+	if migrate {
+		err = SaveProblemsState(state)
+	}
 	return state, err
 }
+
+// StatePath is the problems state file cfr reads and writes.
+func StatePath() string {
+	return getStatePath()
+}
+
+// BuildCacheDir is where compiled binaries are cached, or "" outside a workspace with a .cfr folder.
+func BuildCacheDir() string {
+	if fi, err := os.Stat(cfrDir); err != nil || !fi.IsDir() {
+		return ""
+	}
+	return filepath.Join(cfrDir, "build-cache")
+}
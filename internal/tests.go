@@ -0,0 +1,172 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Tests are stored as files in a tests folder of the problem directory: <name>.in holds the
+// input and <name>.ans the expected answer, if there is one. These files are what gets tested;
+// the problems state only records each test's name and kind.
+const (
+	TestsDir      = "tests"
+	TestInputExt  = ".in"
+	TestAnswerExt = ".ans"
+)
+
+// ProblemDir is the directory of a loaded problem, named "<problemID>. <name>".
+func ProblemDir(problemID string, p ProblemEntry) string {
+	return fmt.Sprintf("%s. %s", problemID, p.Name)
+}
+
+// TestFiles are the input and answer files of a test in a problem directory.
+func TestFiles(dir, name string) (input, answer string) {
+	base := filepath.Join(dir, TestsDir, name)
+	return base + TestInputExt, base + TestAnswerExt
+}
+
+// LoadTests reads the tests in dir's tests folder, ordered by name with numeric names first and
+// in numeric order. Each test gets its kind from the matching entry of meta; tests that were
+// put there by hand are custom.
+func LoadTests(dir string, meta []TestCase) ([]TestCase, error) {
+	kinds := map[string]string{}
+	for _, t := range meta {
+		kinds[t.Name] = t.TestKind()
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, TestsDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var tests []TestCase
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != TestInputExt {
+			continue
+		}
+		t := TestCase{Name: strings.TrimSuffix(e.Name(), TestInputExt), Kind: TestCustom}
+		if kind, ok := kinds[t.Name]; ok {
+			t.Kind = kind
+		}
+		inPath, ansPath := TestFiles(dir, t.Name)
+		input, err := os.ReadFile(inPath)
+		if err != nil {
+			return nil, err
+		}
+		t.Input = string(input)
+		answer, err := os.ReadFile(ansPath)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		t.Output = string(answer)
+		tests = append(tests, t)
+	}
+	sort.SliceStable(tests, func(i, j int) bool {
+		return lessTestName(tests[i].Name, tests[j].Name)
+	})
+	return tests, nil
+}
+
+// lessTestName orders numeric test names by value, before any other names.
+func lessTestName(a, b string) bool {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return na < nb
+	case errA == nil || errB == nil:
+		return errA == nil
+	}
+	return a < b
+}
+
+// SaveTest writes the files of a named test. A test without an answer has no answer file.
+func SaveTest(dir string, t TestCase) error {
+	if err := os.MkdirAll(filepath.Join(dir, TestsDir), 0755); err != nil {
+		return err
+	}
+	inPath, ansPath := TestFiles(dir, t.Name)
+	if err := os.WriteFile(inPath, []byte(t.Input), 0644); err != nil {
+		return err
+	}
+	if t.Output == "" {
+		if err := os.Remove(ansPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(ansPath, []byte(t.Output), 0644)
+}
+
+// RemoveTest deletes the files of a named test.
+func RemoveTest(dir, name string) error {
+	for _, path := range []string{filepath.Join(dir, TestsDir, name+TestInputExt), filepath.Join(dir, TestsDir, name+TestAnswerExt)} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// NextTestName is the name for a new test: the number after the highest numeric name in use,
+// at least two digits wide so the files sort in order.
+func NextTestName(dir string, meta []TestCase) string {
+	last := 0
+	used := func(name string) {
+		if n, err := strconv.Atoi(name); err == nil && n > last {
+			last = n
+		}
+	}
+	for _, t := range meta {
+		used(t.Name)
+	}
+	entries, _ := os.ReadDir(filepath.Join(dir, TestsDir))
+	for _, e := range entries {
+		used(strings.TrimSuffix(strings.TrimSuffix(e.Name(), TestInputExt), TestAnswerExt))
+	}
+	return fmt.Sprintf("%02d", last+1)
+}
+
+// freeTestName is the lowest test number whose name is not in use, so scraped samples come before
+// the custom tests again after a reload.
+func freeTestName(dir string, meta []TestCase) string {
+	for n := 1; ; n++ {
+		name := fmt.Sprintf("%02d", n)
+		inPath, _ := TestFiles(dir, name)
+		taken := fileExists(inPath)
+		for _, t := range meta {
+			taken = taken || t.Name == name
+		}
+		if !taken {
+			return name
+		}
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// storeInlineTests moves the tests that are held inline in p, as loaded from a state file written
+// before tests were stored as files or as just scraped, to files in the problem directory.
+// Only their names and kinds stay in p.
+func storeInlineTests(problemID string, p *ProblemEntry) error {
+	dir := ProblemDir(problemID, *p)
+	for i, t := range p.Tests {
+		if t.Name != "" {
+			continue
+		}
+		t.Name = freeTestName(dir, p.Tests)
+		if err := SaveTest(dir, t); err != nil {
+			return err
+		}
+		p.Tests[i] = TestCase{Name: t.Name, Kind: t.TestKind()}
+	}
+	return nil
+}